@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type loadOptions struct {
+	workers  int
+	rate     float64
+	duration time.Duration
+	// each worker cycles through calls
+	calls []func(context.Context) error
+}
+
+type loadResult struct {
+	elapsed   time.Duration
+	latencies []time.Duration
+	errors    int
+
+	cpu        time.Duration
+	allocs     uint64
+	allocBytes uint64
+
+	// filled in by the caller
+	series         int
+	scrapeDuration time.Duration
+}
+
+// runLoad runs opts.workers workers for opts.duration, at no
+// more than opts.rate calls per second in total.
+func runLoad(ctx context.Context, opts *loadOptions) *loadResult {
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	// with no target rate, tokens stays nil and workers run
+	// flat-out
+	var tokens chan struct{}
+	if opts.rate > 0 {
+		tokens = make(chan struct{}, opts.workers)
+		go generateTokens(ctx, tokens, opts.rate)
+	}
+
+	type workerResult struct {
+		latencies []time.Duration
+		errors    int
+	}
+	results := make([]workerResult, opts.workers)
+
+	cpuBefore := cpuTime()
+	var memBefore runtime.MemStats
+	runtime.ReadMemStats(&memBefore)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := &results[w]
+			for i := w; ; i++ {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+
+				call := opts.calls[i%len(opts.calls)]
+				callStart := time.Now()
+				err := call(ctx)
+				if ctx.Err() != nil {
+					// don't count calls cut short by the end of the run
+					return
+				}
+				res.latencies = append(res.latencies, time.Since(callStart))
+				if err != nil {
+					res.errors++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	res := &loadResult{
+		elapsed: time.Since(start),
+		cpu:     cpuTime() - cpuBefore,
+	}
+	var memAfter runtime.MemStats
+	runtime.ReadMemStats(&memAfter)
+	res.allocs = memAfter.Mallocs - memBefore.Mallocs
+	res.allocBytes = memAfter.TotalAlloc - memBefore.TotalAlloc
+
+	for _, r := range results {
+		res.latencies = append(res.latencies, r.latencies...)
+		res.errors += r.errors
+	}
+	slices.Sort(res.latencies)
+
+	return res
+}
+
+// generateTokens sends to tokens at rate per second until
+// ctx is done.
+func generateTokens(ctx context.Context, tokens chan<- struct{}, rate float64) {
+	t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			select {
+			case tokens <- struct{}{}:
+			default:
+				// workers are saturated, drop the token
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// cpuTime returns the user and system CPU time used by the process
+// so far.
+func cpuTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(latencies)-1) * p)
+	return latencies[i]
+}
+
+func (r *loadResult) print(w io.Writer) {
+	calls := len(r.latencies)
+	fmt.Fprintf(w, "calls: %d (%d errors) in %s\n", calls, r.errors, r.elapsed.Round(time.Millisecond))
+	if calls == 0 {
+		return
+	}
+	fmt.Fprintf(w, "throughput: %.1f calls/s\n", float64(calls)/r.elapsed.Seconds())
+	fmt.Fprintf(w, "latency: p50=%s p90=%s p99=%s max=%s\n",
+		percentile(r.latencies, 0.5),
+		percentile(r.latencies, 0.9),
+		percentile(r.latencies, 0.99),
+		r.latencies[calls-1],
+	)
+	fmt.Fprintf(w, "cpu per call: %s\n", r.cpu/time.Duration(calls))
+	fmt.Fprintf(w, "allocs per call: %d (%d bytes)\n", r.allocs/uint64(calls), r.allocBytes/uint64(calls))
+	fmt.Fprintf(w, "series: %d (scraped in %s)\n", r.series, r.scrapeDuration)
+}
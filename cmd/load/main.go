@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"demo/internal/fakeaws"
+	"demo/internal/otelexport"
+	"demo/internal/policy"
+	"demo/internal/smithyprom"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func main() {
+	if err := mainErr(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainErr() error {
+	var opts loadOptions
+	opts.workers = 8
+	flag.Func("workers", "number of concurrent workers, at least 1 (default 8)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("%d workers, want at least 1", n)
+		}
+		opts.workers = n
+		return nil
+	})
+	flag.Func("rate", "target requests per second across all workers, at most 1e9 (0 for as fast as possible)", func(s string) error {
+		r, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		// any faster and the interval between requests rounds
+		// down to nothing
+		if !(r >= 0 && r <= 1e9) {
+			return fmt.Errorf("rate %v, want between 0 and 1e9", r)
+		}
+		opts.rate = r
+		return nil
+	})
+	flag.DurationVar(&opts.duration, "duration", 10*time.Second, "how long to generate load for")
+	provider := flag.String("provider", "prom", "meter provider to instrument clients with: prom, otel or noop")
+	services := flag.String("services", "s3,dynamodb", "comma-separated services to call: s3, dynamodb")
+	offline := flag.Bool("offline", true, "talk to a local stand-in instead of AWS")
+	var faults fakeaws.Faults
+	faults.RegisterFlags(flag.CommandLine)
+	policyFile := flag.String("policy", "", "instrumentation policy file (JSON); defaults to dropping client-side timings")
+	flag.Parse()
+
+	pol, err := policy.LoadOrDefault(*policyFile)
+	if err != nil {
+		return err
+	}
+
+	promRegistry := prometheus.NewRegistry()
+	meterProvider, err := newMeterProvider(*provider, promRegistry, pol)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("loading aws config: %s", err)
+	}
+	cfg.Region = "us-east-1"
+
+	if *offline {
+		fake, err := fakeaws.Start(&fakeaws.Options{Faults: faults})
+		if err != nil {
+			return fmt.Errorf("starting fake aws: %s", err)
+		}
+		defer fake.Close()
+		fake.Configure(&cfg)
+	}
+
+	opts.calls, err = newCalls(cfg, meterProvider, *services)
+	if err != nil {
+		return err
+	}
+
+	res := runLoad(ctx, &opts)
+
+	// include the cost of a scrape, since that's where some
+	// adapters do their aggregation
+	scrapeStart := time.Now()
+	metricFamilies, err := promRegistry.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %s", err)
+	}
+	res.scrapeDuration = time.Since(scrapeStart)
+	for _, mf := range metricFamilies {
+		res.series += len(mf.GetMetric())
+	}
+
+	fmt.Printf("provider: %s\n", *provider)
+	res.print(os.Stdout)
+
+	return nil
+}
+
+// newMeterProvider returns the named meter provider, exporting
+// to promRegistry.
+func newMeterProvider(name string, promRegistry *prometheus.Registry, pol *policy.Policy) (metrics.MeterProvider, error) {
+	switch name {
+	case "prom":
+		return smithyprom.NewMeterProvider(&smithyprom.Options{
+			Registry:  promRegistry,
+			Namespace: "aws",
+			Views:     []smithyprom.View{pol.PromView()},
+		}), nil
+	case "otel":
+		mp, err := otelexport.NewMeterProvider(promRegistry, &otelexport.Options{
+			// we may have more than one client
+			ScopeInfo: true,
+			Views:     []sdkmetric.View{pol.OTELView()},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting up otel exporter: %s", err)
+		}
+		return smithyotelmetrics.Adapt(mp), nil
+	case "noop":
+		return metrics.NopMeterProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown meter provider %q", name)
+}
+
+// newCalls returns the API calls the workers make, one per
+// requested service.
+func newCalls(cfg aws.Config, meterProvider metrics.MeterProvider, services string) ([]func(context.Context) error, error) {
+	var calls []func(context.Context) error
+	for _, svc := range strings.Split(services, ",") {
+		switch strings.TrimSpace(svc) {
+		case "s3":
+			client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+				o.MeterProvider = meterProvider
+			})
+			calls = append(calls, func(ctx context.Context) error {
+				_, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+				return err
+			})
+		case "dynamodb":
+			client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+				o.MeterProvider = meterProvider
+			})
+			calls = append(calls, func(ctx context.Context) error {
+				_, err := client.ListTables(ctx, &dynamodb.ListTablesInput{})
+				return err
+			})
+		default:
+			return nil, fmt.Errorf("unknown service %q", svc)
+		}
+	}
+	return calls, nil
+}
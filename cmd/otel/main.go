@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"reflect"
 
@@ -12,11 +14,17 @@ import (
 	"github.com/aws/smithy-go/metrics"
 	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
 
-	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 
+	"demo/internal/callmetrics"
+	"demo/internal/exposition"
+	"demo/internal/fakeaws"
+	"demo/internal/otelexport"
+	"demo/internal/policy"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
 )
 
 func main() {
@@ -27,12 +35,44 @@ func main() {
 }
 
 func mainErr() error {
+	offline := flag.Bool("offline", false, "talk to a local stand-in instead of AWS")
+	var faults fakeaws.Faults
+	faults.RegisterFlags(flag.CommandLine)
+	policyFile := flag.String("policy", "", "instrumentation policy file (JSON); defaults to dropping client-side timings")
+	utf8Names := flag.Bool("utf8-names", false, "keep UTF-8 metric and label names instead of escaping them (Prometheus 3)")
+	withoutUnits := flag.Bool("without-units", false, "don't add unit suffixes to metric names")
+	withoutCounterSuffixes := flag.Bool("without-counter-suffixes", false, "don't add _total to counter names")
+	format := flag.String("format", "text", "format to dump metrics in: text, openmetrics or protobuf")
+	listen := flag.String("listen", "", "serve metrics over HTTP on this address after the API calls, instead of dumping them")
+	flag.Parse()
+
+	dumpFormat, err := exposition.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	if *utf8Names {
+		model.NameValidationScheme = model.UTF8Validation
+	}
+
+	pol, err := policy.LoadOrDefault(*policyFile)
+	if err != nil {
+		return err
+	}
+
 	// set up our metric-exporter
 	promRegistry := prometheus.NewRegistry()
-	meterProvider := setupOTELExporter(promRegistry)
+	meterProvider := setupOTELExporter(promRegistry, pol, &otelexport.Options{
+		WithoutUnits:           *withoutUnits,
+		WithoutCounterSuffixes: *withoutCounterSuffixes,
+	})
+
+	gatherer := prometheus.Gatherer(promRegistry)
 
 	// for demo purposes, scrape all prom metrics and dump to stdout
-	defer scrapePromMetrics(promRegistry)
+	if *listen == "" {
+		defer scrapePromMetrics(gatherer, dumpFormat)
+	}
 
 	ctx := context.Background()
 
@@ -41,7 +81,31 @@ func mainErr() error {
 		return fmt.Errorf("loading aws config: %s", err)
 	}
 
-	addMeterProvider(&cfg, smithyotelmetrics.Adapt(meterProvider))
+	if *offline {
+		fake, err := fakeaws.Start(&fakeaws.Options{Faults: faults})
+		if err != nil {
+			return fmt.Errorf("starting fake aws: %s", err)
+		}
+		defer fake.Close()
+		fake.Configure(&cfg)
+	}
+
+	smithyMeterProvider := smithyotelmetrics.Adapt(meterProvider)
+	addMeterProvider(&cfg, smithyMeterProvider)
+
+	responses, err := callmetrics.Responses(smithyMeterProvider)
+	if err != nil {
+		return err
+	}
+	payloadSizes, err := callmetrics.PayloadSizes(smithyMeterProvider)
+	if err != nil {
+		return err
+	}
+	inFlight, err := callmetrics.InFlight(smithyMeterProvider)
+	if err != nil {
+		return err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, responses, payloadSizes, inFlight, callmetrics.ResourceNames(nil))
 
 	s3c := s3.NewFromConfig(cfg)
 
@@ -62,69 +126,39 @@ func mainErr() error {
 		return fmt.Errorf("list buckets: %s", err)
 	}
 
+	if *listen != "" {
+		return serveMetrics(*listen, gatherer)
+	}
+
 	return nil
 }
 
 // setupOTELExporter creates an OTEL meter-provider whose back-end is the
 // provided Prometheus registry.
-func setupOTELExporter(promRegistry *prometheus.Registry) *sdkmetric.MeterProvider {
-	// create an otel metric-exporter associated with the
-	// default prometheus registry
-	metricExporter, err := otelprom.New(
-		otelprom.WithNamespace("aws"),
-		otelprom.WithoutScopeInfo(),
-		otelprom.WithoutTargetInfo(),
-		otelprom.WithRegisterer(promRegistry),
-
-		// OTEL default buckets assume you're using milliseconds. Substitute defaults
-		// appropriate for units of seconds.
-		//
-		// https://github.com/open-telemetry/opentelemetry-go/issues/5821
-		otelprom.WithAggregationSelector(func(ik sdkmetric.InstrumentKind) sdkmetric.Aggregation {
-			switch ik {
-			case sdkmetric.InstrumentKindHistogram:
-				return sdkmetric.AggregationExplicitBucketHistogram{
-					Boundaries: prometheus.DefBuckets,
-					NoMinMax:   false,
-				}
-			default:
-				return sdkmetric.DefaultAggregationSelector(ik)
-			}
-		}),
-	)
+func setupOTELExporter(promRegistry *prometheus.Registry, pol *policy.Policy, opts *otelexport.Options) *sdkmetric.MeterProvider {
+	opts.Views = []sdkmetric.View{pol.OTELView()}
+	meterProvider, err := otelexport.NewMeterProvider(promRegistry, opts)
 	if err != nil {
 		panic(err)
 	}
 
-	// create a meter-provider associated with the exporter
-	meterProvider := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(metricExporter),
-		// opt-out of metrics which appear to be purely client-side computation
-		sdkmetric.WithView(func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
-			switch i.Name {
-			case "client.call.serialization_duration", "client.call.deserialization_duration", "client.call.resolve_endpoint_duration", "client.call.auth.signing_duration":
-				return sdkmetric.Stream{Aggregation: sdkmetric.AggregationDrop{}}, true
-			}
-			return sdkmetric.Stream{}, false
-		}),
-	)
-
 	return meterProvider
 }
 
 // for demo purposes, dump all prom metrics to stdout
-func scrapePromMetrics(promRegistry *prometheus.Registry) {
-	metricFamilies, err := promRegistry.Gather()
-	if err != nil {
+func scrapePromMetrics(gatherer prometheus.Gatherer, format expfmt.Format) {
+	if err := exposition.Write(os.Stdout, gatherer, format); err != nil {
 		panic(err)
 	}
+}
 
-	encoder := expfmt.NewEncoder(os.Stdout, expfmt.NewFormat(expfmt.TypeTextPlain))
-	for _, mf := range metricFamilies {
-		if err := encoder.Encode(mf); err != nil {
-			panic(err)
-		}
-	}
+// serveMetrics serves metrics for scraping, in whichever format the
+// scraper asks for, until the process is killed.
+func serveMetrics(addr string, gatherer prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exposition.Handler(gatherer))
+	fmt.Fprintf(os.Stderr, "serving metrics on http://%s/metrics\n", addr)
+	return http.ListenAndServe(addr, mux)
 }
 
 // well this is gross.
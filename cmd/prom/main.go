@@ -2,17 +2,29 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/smithy-go/metrics"
 
+	"demo/internal/callmetrics"
+	"demo/internal/exposition"
+	"demo/internal/fakeaws"
+	"demo/internal/policy"
+	"demo/internal/smithyprom"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
 )
 
 func main() {
@@ -23,16 +35,64 @@ func main() {
 }
 
 func mainErr() error {
+	offline := flag.Bool("offline", false, "talk to a local stand-in instead of AWS")
+	var faults fakeaws.Faults
+	faults.RegisterFlags(flag.CommandLine)
+	policyFile := flag.String("policy", "", "instrumentation policy file (JSON); defaults to dropping client-side timings")
+	utf8Names := flag.Bool("utf8-names", false, "keep UTF-8 metric and label names instead of escaping them (Prometheus 3)")
+	withoutUnits := flag.Bool("without-units", false, "don't add unit suffixes to metric names")
+	withoutCounterSuffixes := flag.Bool("without-counter-suffixes", false, "don't add _total to counter names")
+	consumedCapacity := flag.Bool("consumed-capacity", false, "ask DynamoDB for the capacity each call consumes, and export it")
+	minMax := flag.Bool("histogram-min-max", false, "also export the min and max of each histogram over the last minute")
+	format := flag.String("format", "text", "format to dump metrics in: text, openmetrics or protobuf")
+	listen := flag.String("listen", "", "serve metrics over HTTP on this address after the API calls, instead of dumping them")
+	flag.Parse()
+
+	dumpFormat, err := exposition.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	if *utf8Names {
+		model.NameValidationScheme = model.UTF8Validation
+	}
+
+	pol, err := policy.LoadOrDefault(*policyFile)
+	if err != nil {
+		return err
+	}
+
 	// set up our metric-exporter
 	promRegistry := prometheus.NewRegistry()
-	meterProvider := newMeterProvider(&meterProviderOptions{
-		registry:  promRegistry,
-		namespace: "aws",
-		filter:    filterMetrics,
-	})
+	opts := &smithyprom.Options{
+		Registry:  promRegistry,
+		Namespace: "aws",
+		Views:     []smithyprom.View{pol.PromView()},
+		// label the AWS SDK's call metrics by resource, too
+		ContextLabels: []smithyprom.ContextLabel{{
+			Key:     callmetrics.ResourceKey,
+			Extract: callmetrics.ResourceName,
+			Filter:  resourceLabelled,
+			// bounded by callmetrics.ResourceNames
+			Unbounded: true,
+		}},
+		Naming: smithyprom.NamingStrategy{
+			UTF8:                   *utf8Names,
+			WithoutUnits:           *withoutUnits,
+			WithoutCounterSuffixes: *withoutCounterSuffixes,
+		},
+	}
+	if *minMax {
+		opts.HistogramMinMax = &smithyprom.MinMaxOptions{}
+	}
+	meterProvider := smithyprom.NewMeterProvider(opts)
+
+	gatherer := meterProvider.Gatherer(promRegistry)
 
 	// for demo purposes, scrape all prom metrics and dump to stdout
-	defer scrapePromMetrics(promRegistry)
+	if *listen == "" {
+		defer scrapePromMetrics(gatherer, dumpFormat)
+	}
 
 	ctx := context.Background()
 
@@ -42,16 +102,43 @@ func mainErr() error {
 	}
 	cfg.Region = "us-east-1"
 
+	if *offline {
+		fake, err := fakeaws.Start(&fakeaws.Options{Faults: faults})
+		if err != nil {
+			return fmt.Errorf("starting fake aws: %s", err)
+		}
+		defer fake.Close()
+		fake.Configure(&cfg)
+	}
+
+	responses, err := callmetrics.Responses(meterProvider)
+	if err != nil {
+		return err
+	}
+	payloadSizes, err := callmetrics.PayloadSizes(meterProvider)
+	if err != nil {
+		return err
+	}
+	inFlight, err := callmetrics.InFlight(meterProvider)
+	if err != nil {
+		return err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, responses, payloadSizes, inFlight, callmetrics.ResourceNames(nil))
+
 	err = callS3(ctx, meterProvider, cfg)
 	if err != nil {
 		return err
 	}
 
-	err = callDynamoDB(ctx, meterProvider, cfg)
+	err = callDynamoDB(ctx, meterProvider, cfg, *consumedCapacity)
 	if err != nil {
 		return err
 	}
 
+	if *listen != "" {
+		return serveMetrics(*listen, gatherer)
+	}
+
 	return nil
 }
 
@@ -80,40 +167,75 @@ func callS3(ctx context.Context, meterProvider metrics.MeterProvider, cfg aws.Co
 	return nil
 }
 
-func callDynamoDB(ctx context.Context, meterProvider metrics.MeterProvider, cfg aws.Config) error {
+func callDynamoDB(ctx context.Context, meterProvider metrics.MeterProvider, cfg aws.Config, requestCapacity bool) error {
+	capacity, err := callmetrics.ConsumedCapacity(meterProvider, &callmetrics.CapacityOptions{
+		Request: requestCapacity,
+	})
+	if err != nil {
+		return err
+	}
+
 	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
 		o.MeterProvider = meterProvider
+		o.APIOptions = append(o.APIOptions, capacity)
 	})
 
-	_, err := client.ListTables(ctx, &dynamodb.ListTablesInput{})
+	tables, err := client.ListTables(ctx, &dynamodb.ListTablesInput{})
 	if err != nil {
 		return err
 	}
 
 	_, err = client.ListGlobalTables(ctx, &dynamodb.ListGlobalTablesInput{})
+	if err != nil {
+		return err
+	}
 
-	return err
-}
+	// the local stand-in has this table, but an AWS account needn't
+	if !slices.Contains(tables.TableNames, "demo-table") {
+		return nil
+	}
 
-func filterMetrics(name string) bool {
-	switch name {
-	case "client.call.serialization_duration", "client.call.deserialization_duration", "client.call.resolve_endpoint_duration", "client.call.auth.signing_duration":
-		return false
+	key := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "demo"}}
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("demo-table"),
+		Item:      key,
+	})
+	if err != nil {
+		return fmt.Errorf("put item: %s", err)
 	}
-	return true
+
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("demo-table"),
+		Key:       key,
+	})
+	if err != nil {
+		return fmt.Errorf("get item: %s", err)
+	}
+
+	return nil
+}
+
+// resourceLabelled returns whether the metric named name is recorded
+// within an operation's middleware, where the resource it's on is
+// known. The HTTP metrics, such as connection-pool usage, are about
+// connections rather than resources, and the AWS SDK records the
+// overall call duration outside of the middleware.
+func resourceLabelled(name string) bool {
+	return strings.HasPrefix(name, "client.call.") && name != "client.call.duration"
 }
 
 // for demo purposes, dump all prom metrics to stdout
-func scrapePromMetrics(promRegistry *prometheus.Registry) {
-	metricFamilies, err := promRegistry.Gather()
-	if err != nil {
+func scrapePromMetrics(gatherer prometheus.Gatherer, format expfmt.Format) {
+	if err := exposition.Write(os.Stdout, gatherer, format); err != nil {
 		panic(err)
 	}
+}
 
-	encoder := expfmt.NewEncoder(os.Stdout, expfmt.NewFormat(expfmt.TypeTextPlain))
-	for _, mf := range metricFamilies {
-		if err := encoder.Encode(mf); err != nil {
-			panic(err)
-		}
-	}
+// serveMetrics serves metrics for scraping, in whichever format the
+// scraper asks for, until the process is killed.
+func serveMetrics(addr string, gatherer prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exposition.Handler(gatherer))
+	fmt.Fprintf(os.Stderr, "serving metrics on http://%s/metrics\n", addr)
+	return http.ListenAndServe(addr, mux)
 }
@@ -0,0 +1,233 @@
+package callmetrics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// CapacityOptions configures [ConsumedCapacity].
+type CapacityOptions struct {
+	// Request sets ReturnConsumedCapacity to INDEXES on inputs which
+	// support it and don't set it themselves. Otherwise only
+	// operations which ask for consumed capacity are recorded.
+	Request bool
+	// Tables is the allowlist of table names which label capacity as
+	// themselves. Other tables are labelled [Other]. If empty, the
+	// busiest MaxTables tables seen so far are allowed.
+	Tables []string
+	// MaxTables bounds the number of tables when there's no
+	// allowlist. If zero, it's 20.
+	MaxTables int
+}
+
+// ConsumedCapacity returns an API option for DynamoDB clients which
+// records the read, write and total capacity units each operation
+// consumed, by table and index. The table's own capacity has an
+// empty index label. Operations which only return the total, rather
+// than the breakdown by index, are recorded as the table's.
+//
+// opts may be nil.
+func ConsumedCapacity(mp metrics.MeterProvider, opts *CapacityOptions) (func(*middleware.Stack) error, error) {
+	if opts == nil {
+		opts = &CapacityOptions{}
+	}
+	maxTables := opts.MaxTables
+	if maxTables == 0 {
+		maxTables = 20
+	}
+
+	if len(opts.Tables) > 0 {
+		maxTables = 0
+	}
+
+	m := &consumedCapacity{
+		request: opts.Request,
+		tables:  newBoundedLabel(opts.Tables, maxTables, 0),
+	}
+	for _, c := range []struct {
+		counter     *metrics.Float64Counter
+		name        string
+		description string
+	}{
+		{&m.read, "dynamodb.consumed_capacity.read", "Read capacity units consumed"},
+		{&m.write, "dynamodb.consumed_capacity.write", "Write capacity units consumed"},
+		{&m.total, "dynamodb.consumed_capacity", "Capacity units consumed, read and write"},
+	} {
+		var err error
+		*c.counter, err = mp.Meter(scope).Float64Counter(c.name, func(o *metrics.InstrumentOptions) {
+			o.UnitLabel = "{capacity_unit}"
+			o.Description = c.description
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating %s counter: %w", c.name, err)
+		}
+	}
+
+	return func(s *middleware.Stack) error {
+		return s.Initialize.Add(m, middleware.After)
+	}, nil
+}
+
+type consumedCapacity struct {
+	request            bool
+	tables             *boundedLabel
+	read, write, total metrics.Float64Counter
+}
+
+// ID implements middleware.InitializeMiddleware.
+func (m *consumedCapacity) ID() string {
+	return "callmetrics.ConsumedCapacity"
+}
+
+// HandleInitialize implements middleware.InitializeMiddleware.
+func (m *consumedCapacity) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+	middleware.InitializeOutput, middleware.Metadata, error,
+) {
+	if m.request {
+		in.Parameters = requestCapacity(in.Parameters)
+	}
+
+	out, md, err := next.HandleInitialize(ctx, in)
+	if err != nil {
+		return out, md, err
+	}
+
+	for _, cc := range consumed(out.Result) {
+		table := m.tables.value(aws.ToString(cc.TableName))
+		if cc.Table == nil && cc.GlobalSecondaryIndexes == nil && cc.LocalSecondaryIndexes == nil {
+			m.record(ctx, table, "", types.Capacity{
+				ReadCapacityUnits:  cc.ReadCapacityUnits,
+				WriteCapacityUnits: cc.WriteCapacityUnits,
+				CapacityUnits:      cc.CapacityUnits,
+			})
+			continue
+		}
+		if cc.Table != nil {
+			m.record(ctx, table, "", *cc.Table)
+		}
+		for index, c := range cc.GlobalSecondaryIndexes {
+			m.record(ctx, table, index, c)
+		}
+		for index, c := range cc.LocalSecondaryIndexes {
+			m.record(ctx, table, index, c)
+		}
+	}
+	return out, md, err
+}
+
+func (m *consumedCapacity) record(ctx context.Context, table, index string, c types.Capacity) {
+	attrs := func(o *metrics.RecordMetricOptions) {
+		o.Properties.Set("aws.dynamodb.table", table)
+		o.Properties.Set("aws.dynamodb.index", index)
+	}
+	if c.ReadCapacityUnits != nil {
+		m.read.Add(ctx, *c.ReadCapacityUnits, attrs)
+	}
+	if c.WriteCapacityUnits != nil {
+		m.write.Add(ctx, *c.WriteCapacityUnits, attrs)
+	}
+	if c.CapacityUnits != nil {
+		m.total.Add(ctx, *c.CapacityUnits, attrs)
+	}
+}
+
+// requestCapacity returns a copy of a DynamoDB input asking for
+// consumed capacity by index, unless it says otherwise. The caller's
+// input isn't modified.
+func requestCapacity(params any) any {
+	switch in := params.(type) {
+	case *dynamodb.GetItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.PutItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.UpdateItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.DeleteItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.QueryInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.ScanInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.BatchGetItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.BatchWriteItemInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.TransactGetItemsInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.TransactWriteItemsInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.ExecuteStatementInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.BatchExecuteStatementInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	case *dynamodb.ExecuteTransactionInput:
+		cp := *in
+		return withIndexes(&cp, &cp.ReturnConsumedCapacity)
+	}
+	return params
+}
+
+// withIndexes sets rcc, a field of in, to ask for consumed capacity by
+// index if it's unset.
+func withIndexes[T any](in *T, rcc *types.ReturnConsumedCapacity) *T {
+	if *rcc == "" {
+		*rcc = types.ReturnConsumedCapacityIndexes
+	}
+	return in
+}
+
+// consumed returns the consumed capacity in a DynamoDB output, if
+// any.
+func consumed(result any) []types.ConsumedCapacity {
+	var one *types.ConsumedCapacity
+	switch out := result.(type) {
+	case *dynamodb.GetItemOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.PutItemOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.UpdateItemOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.DeleteItemOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.QueryOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.ScanOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.ExecuteStatementOutput:
+		one = out.ConsumedCapacity
+	case *dynamodb.BatchGetItemOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.BatchWriteItemOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.TransactGetItemsOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.TransactWriteItemsOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.BatchExecuteStatementOutput:
+		return out.ConsumedCapacity
+	case *dynamodb.ExecuteTransactionOutput:
+		return out.ConsumedCapacity
+	}
+	if one == nil {
+		return nil
+	}
+	return []types.ConsumedCapacity{*one}
+}
@@ -0,0 +1,79 @@
+package callmetrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConsumedCapacity(t *testing.T) {
+	cfg, reg, mp := setup(t)
+	capacity, err := ConsumedCapacity(mp, &CapacityOptions{Request: true, Tables: []string{"demo-table"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIOptions = append(cfg.APIOptions, capacity)
+	client := dynamodb.NewFromConfig(cfg)
+	ctx := context.Background()
+	key := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}}
+
+	get := &dynamodb.GetItemInput{TableName: aws.String("demo-table"), Key: key}
+	if _, err := client.GetItem(ctx, get); err != nil {
+		t.Fatal(err)
+	}
+	if get.ReturnConsumedCapacity != "" {
+		t.Errorf("the caller's input was modified")
+	}
+	if _, err := client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("demo-table"), Item: key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("demo-table"), IndexName: aws.String("by-owner")}); err != nil {
+		t.Fatal(err)
+	}
+	// not in the allowlist
+	if _, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("demo-sessions"), Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	// the caller asked for the total only
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String("demo-table"),
+		Key:                    key,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the caller asked for nothing
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String("demo-table"),
+		Key:                    key,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityNone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP dynamodb_consumed_capacity_read_total Read capacity units consumed
+# TYPE dynamodb_consumed_capacity_read_total counter
+dynamodb_consumed_capacity_read_total{aws_dynamodb_index="",aws_dynamodb_table="demo-table"} 1
+dynamodb_consumed_capacity_read_total{aws_dynamodb_index="",aws_dynamodb_table="other"} 0.5
+dynamodb_consumed_capacity_read_total{aws_dynamodb_index="by-owner",aws_dynamodb_table="demo-table"} 0.5
+# HELP dynamodb_consumed_capacity_total Capacity units consumed, read and write
+# TYPE dynamodb_consumed_capacity_total counter
+dynamodb_consumed_capacity_total{aws_dynamodb_index="",aws_dynamodb_table="demo-table"} 2
+dynamodb_consumed_capacity_total{aws_dynamodb_index="",aws_dynamodb_table="other"} 0.5
+dynamodb_consumed_capacity_total{aws_dynamodb_index="by-owner",aws_dynamodb_table="demo-table"} 0.5
+# HELP dynamodb_consumed_capacity_write_total Write capacity units consumed
+# TYPE dynamodb_consumed_capacity_write_total counter
+dynamodb_consumed_capacity_write_total{aws_dynamodb_index="",aws_dynamodb_table="demo-table"} 1
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
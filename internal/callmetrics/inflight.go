@@ -0,0 +1,58 @@
+package callmetrics
+
+import (
+	"context"
+	"fmt"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// InFlight returns an API option which counts the operations in
+// progress, by service and operation, with an up-down counter. An
+// operation is in progress from when it's initialized to when it
+// returns, whether it succeeds, fails or panics, so retries and time
+// spent waiting for a connection are included.
+func InFlight(mp metrics.MeterProvider) (func(*middleware.Stack) error, error) {
+	c, err := mp.Meter(scope).Int64UpDownCounter("client.call.in_flight",
+		func(o *metrics.InstrumentOptions) {
+			o.UnitLabel = "{operation}"
+			o.Description = "The number of operations in progress"
+		})
+	if err != nil {
+		return nil, fmt.Errorf("creating in-flight counter: %w", err)
+	}
+
+	m := &inFlight{counter: c}
+	return func(s *middleware.Stack) error {
+		// after the SDK's initialize middleware, which sets the
+		// service and operation
+		return s.Initialize.Add(m, middleware.After)
+	}, nil
+}
+
+type inFlight struct {
+	counter metrics.Int64UpDownCounter
+}
+
+// ID implements middleware.InitializeMiddleware.
+func (m *inFlight) ID() string {
+	return "callmetrics.InFlight"
+}
+
+// HandleInitialize implements middleware.InitializeMiddleware.
+func (m *inFlight) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+	middleware.InitializeOutput, middleware.Metadata, error,
+) {
+	service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+	attrs := func(o *metrics.RecordMetricOptions) {
+		o.Properties.Set("rpc.service", service)
+		o.Properties.Set("rpc.method", operation)
+	}
+
+	m.counter.Add(ctx, 1, attrs)
+	defer m.counter.Add(ctx, -1, attrs)
+
+	return next.HandleInitialize(ctx, in)
+}
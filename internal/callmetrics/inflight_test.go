@@ -0,0 +1,116 @@
+package callmetrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"demo/internal/fakeaws"
+)
+
+// inside adds a finalize middleware which runs f while the operation
+// is in flight.
+func inside(f func()) func(*middleware.Stack) error {
+	return func(s *middleware.Stack) error {
+		return s.Finalize.Add(middleware.FinalizeMiddlewareFunc("inside", func(
+			ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			f()
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+	}
+}
+
+func inFlightMetrics(n int) string {
+	return fmt.Sprintf(`
+# HELP client_call_in_flight The number of operations in progress
+# TYPE client_call_in_flight gauge
+client_call_in_flight{rpc_method="ListTables",rpc_service="DynamoDB"} %d
+`, n)
+}
+
+func TestInFlight(t *testing.T) {
+	tests := []struct {
+		testName string
+		script   []fakeaws.Fault
+		panics   bool
+	}{
+		{testName: "success"},
+		{testName: "error", script: []fakeaws.Fault{fakeaws.FaultThrottle}},
+		{testName: "panic", panics: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			cfg, reg, mp := setup(t, tt.script...)
+			inFlight, err := InFlight(mp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			during := func() {
+				if err := testutil.GatherAndCompare(reg, strings.NewReader(inFlightMetrics(1))); err != nil {
+					t.Errorf("during the call: %s", err)
+				}
+				if tt.panics {
+					panic("boom")
+				}
+			}
+			cfg.APIOptions = append(cfg.APIOptions, inFlight, inside(during))
+
+			func() {
+				defer func() {
+					if r := recover(); (r != nil) != tt.panics {
+						t.Errorf("recovered %v", r)
+					}
+				}()
+				dynamodb.NewFromConfig(cfg).ListTables(context.Background(), &dynamodb.ListTablesInput{})
+			}()
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(inFlightMetrics(0))); err != nil {
+				t.Errorf("after the call: %s", err)
+			}
+		})
+	}
+}
+
+// the counter is shared between concurrent calls
+func TestInFlightConcurrent(t *testing.T) {
+	cfg, reg, mp := setup(t)
+	inFlight, err := InFlight(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each call waits inside until the other is in flight too
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	cfg.APIOptions = append(cfg.APIOptions, inFlight, inside(func() {
+		arrived <- struct{}{}
+		<-release
+	}))
+	client := dynamodb.NewFromConfig(cfg)
+
+	done := make(chan struct{})
+	for range 2 {
+		go func() {
+			client.ListTables(context.Background(), &dynamodb.ListTablesInput{})
+			done <- struct{}{}
+		}()
+	}
+	<-arrived
+	<-arrived
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(inFlightMetrics(2))); err != nil {
+		t.Error(err)
+	}
+	close(release)
+	<-done
+	<-done
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(inFlightMetrics(0))); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+package callmetrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Other is the label value which stands in for values a label's
+// bounds don't allow.
+const Other = "other"
+
+// boundedLabel bounds the number of distinct values of a label, such
+// as a table name. Values in the allowlist are kept, and then the max
+// busiest other values seen so far. The rest are hashed into one of
+// buckets values such as "hash-3" or, if there are no buckets, become
+// [Other].
+//
+// The busiest values are found with a space-saving counter: values
+// which aren't kept have their counts estimated in as many slots as
+// there are kept values, each new value taking the least counted
+// one's slot and its count plus one. A value is kept once its
+// estimate is higher than the least counted kept value, which is
+// then estimated like the others. Series already recorded with a
+// value which stops being kept are left as they are.
+type boundedLabel struct {
+	allow   map[string]bool
+	max     int
+	buckets int
+
+	mu sync.Mutex
+	// kept and estimated count how often values are seen, for the
+	// values kept and the others
+	kept      map[string]uint64
+	estimated map[string]uint64
+}
+
+func newBoundedLabel(allow []string, max, buckets int) *boundedLabel {
+	l := &boundedLabel{
+		max:       max,
+		buckets:   buckets,
+		kept:      make(map[string]uint64),
+		estimated: make(map[string]uint64),
+	}
+	if len(allow) > 0 {
+		l.allow = make(map[string]bool, len(allow))
+		for _, v := range allow {
+			l.allow[v] = true
+		}
+	}
+	return l
+}
+
+// value returns the label value for v.
+func (l *boundedLabel) value(v string) string {
+	if l.allow[v] {
+		return v
+	}
+
+	if l.max > 0 && l.keep(v) {
+		return v
+	}
+
+	if l.buckets > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(v))
+		return fmt.Sprintf("hash-%d", h.Sum32()%uint32(l.buckets))
+	}
+	return Other
+}
+
+// keep counts v, and returns whether it's one of the busiest values.
+func (l *boundedLabel) keep(v string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n, ok := l.kept[v]; ok {
+		l.kept[v] = n + 1
+		return true
+	}
+	if len(l.kept) < l.max {
+		l.kept[v] = 1
+		return true
+	}
+
+	n, ok := l.estimated[v]
+	if !ok && len(l.estimated) >= l.max {
+		least, count := leastCounted(l.estimated)
+		delete(l.estimated, least)
+		n = count
+	}
+	n++
+
+	least, count := leastCounted(l.kept)
+	if n <= count {
+		l.estimated[v] = n
+		return false
+	}
+	delete(l.kept, least)
+	l.estimated[least] = count
+	delete(l.estimated, v)
+	l.kept[v] = n
+	return true
+}
+
+// leastCounted returns the value with the lowest count in counts, the
+// first in sorted order if several have it.
+func leastCounted(counts map[string]uint64) (string, uint64) {
+	var least string
+	var count uint64
+	first := true
+	for v, n := range counts {
+		if first || n < count || n == count && v < least {
+			least, count = v, n
+			first = false
+		}
+	}
+	return least, count
+}
@@ -0,0 +1,57 @@
+package callmetrics
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBoundedLabel(t *testing.T) {
+	tests := []struct {
+		testName string
+		label    *boundedLabel
+		values   []string
+		want     []string
+	}{
+		{
+			testName: "allowlist",
+			label:    newBoundedLabel([]string{"a", "b"}, 0, 0),
+			values:   []string{"a", "c", "b", "a"},
+			want:     []string{"a", Other, "b", "a"},
+		},
+		{
+			testName: "first seen",
+			label:    newBoundedLabel(nil, 2, 0),
+			values:   []string{"a", "b", "c", "a", "b", "c"},
+			want:     []string{"a", "b", Other, "a", "b", Other},
+		},
+		{
+			testName: "busiest",
+			label:    newBoundedLabel(nil, 1, 0),
+			values:   []string{"a", "b", "b", "b", "a", "a", "a"},
+			want:     []string{"a", Other, "b", "b", Other, Other, "a"},
+		},
+		{
+			testName: "allowlist then first seen",
+			label:    newBoundedLabel([]string{"a"}, 1, 0),
+			values:   []string{"b", "a", "c", "b"},
+			want:     []string{"b", "a", Other, "b"},
+		},
+		{
+			testName: "hashed",
+			label:    newBoundedLabel([]string{"a"}, 0, 4),
+			values:   []string{"a", "b", "c", "b"},
+			want:     []string{"a", "hash-1", "hash-2", "hash-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var got []string
+			for _, v := range tt.values {
+				got = append(got, tt.label.value(v))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package callmetrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// PayloadSizes returns an API option which records the size of the
+// request and response bodies of each attempt, in bytes. Both
+// adapters default histograms of bytes to byte-sized buckets.
+//
+// Request sizes are known before they're sent, except for unseekable
+// streams, which aren't recorded. Response sizes are the
+// Content-Length or, if there isn't one, the number of bytes read
+// from the body by the time it's read to the end or closed. Streamed
+// responses, such as from S3's GetObject, are only recorded once the
+// caller is done with them.
+func PayloadSizes(mp metrics.MeterProvider) (func(*middleware.Stack) error, error) {
+	m := mp.Meter(scope)
+
+	req, err := m.Float64Histogram("http.client.request.body.size", func(o *metrics.InstrumentOptions) {
+		o.UnitLabel = "By"
+		o.Description = "The size of HTTP request bodies"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating request size histogram: %w", err)
+	}
+	rsp, err := m.Float64Histogram("http.client.response.body.size", func(o *metrics.InstrumentOptions) {
+		o.UnitLabel = "By"
+		o.Description = "The size of HTTP response bodies"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating response size histogram: %w", err)
+	}
+
+	reqm := &requestSize{histogram: req}
+	rspm := &responseSize{histogram: rsp}
+	return func(s *middleware.Stack) error {
+		// finalize is after retries and deserialize inside them,
+		// so both see every attempt. Deserialize middleware added
+		// after the operation's deserializer sees the body first.
+		if err := s.Finalize.Add(reqm, middleware.After); err != nil {
+			return err
+		}
+		return s.Deserialize.Add(rspm, middleware.After)
+	}, nil
+}
+
+type requestSize struct {
+	histogram metrics.Float64Histogram
+}
+
+// ID implements middleware.FinalizeMiddleware.
+func (m *requestSize) ID() string {
+	return "callmetrics.RequestSize"
+}
+
+// HandleFinalize implements middleware.FinalizeMiddleware.
+func (m *requestSize) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+	middleware.FinalizeOutput, middleware.Metadata, error,
+) {
+	if req, ok := in.Request.(*smithyhttp.Request); ok {
+		n := req.ContentLength
+		if n < 0 {
+			if l, ok, err := req.StreamLength(); ok && err == nil {
+				n = l
+			}
+		}
+		if n >= 0 {
+			m.histogram.Record(ctx, float64(n), withOperation(ctx))
+		}
+	}
+	return next.HandleFinalize(ctx, in)
+}
+
+type responseSize struct {
+	histogram metrics.Float64Histogram
+}
+
+// ID implements middleware.DeserializeMiddleware.
+func (m *responseSize) ID() string {
+	return "callmetrics.ResponseSize"
+}
+
+// HandleDeserialize implements middleware.DeserializeMiddleware.
+func (m *responseSize) HandleDeserialize(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (
+	middleware.DeserializeOutput, middleware.Metadata, error,
+) {
+	out, md, err := next.HandleDeserialize(ctx, in)
+
+	rsp, ok := out.RawResponse.(*smithyhttp.Response)
+	if !ok {
+		return out, md, err
+	}
+	if rsp.ContentLength >= 0 {
+		m.histogram.Record(ctx, float64(rsp.ContentLength), withOperation(ctx))
+	} else if rsp.Body != nil {
+		rsp.Body = &countingBody{ReadCloser: rsp.Body, record: func(n int64) {
+			m.histogram.Record(ctx, float64(n), withOperation(ctx))
+		}}
+	}
+	return out, md, err
+}
+
+// countingBody counts the bytes read from a response body, and
+// records them once it's read to the end or closed.
+type countingBody struct {
+	io.ReadCloser
+	n      int64
+	record func(n int64)
+	once   sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) done() {
+	b.once.Do(func() { b.record(b.n) })
+}
@@ -0,0 +1,111 @@
+package callmetrics
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	dto "github.com/prometheus/client_model/go"
+
+	"demo/internal/fakeaws"
+)
+
+func TestPayloadSizes(t *testing.T) {
+	cfg, reg, mp := setup(t, fakeaws.FaultNone, fakeaws.FaultThrottle)
+	sizes, err := PayloadSizes(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIOptions = append(cfg.APIOptions, sizes)
+
+	ctx := context.Background()
+	if _, err := s3.NewFromConfig(cfg).ListBuckets(ctx, &s3.ListBucketsInput{}); err != nil {
+		t.Fatal(err)
+	}
+	// errors have bodies too
+	dynamodb.NewFromConfig(cfg).ListTables(ctx, &dynamodb.ListTablesInput{})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*dto.Histogram)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			var op string
+			for _, l := range m.Label {
+				if l.GetName() == "rpc_method" {
+					op = l.GetValue()
+				}
+			}
+			got[mf.GetName()+" "+op] = m.GetHistogram()
+		}
+	}
+
+	for _, tt := range []struct {
+		series  string
+		nonZero bool
+	}{
+		{series: "http_client_request_body_size_bytes ListBuckets"},
+		{series: "http_client_request_body_size_bytes ListTables", nonZero: true},
+		{series: "http_client_response_body_size_bytes ListBuckets", nonZero: true},
+		{series: "http_client_response_body_size_bytes ListTables", nonZero: true},
+	} {
+		h, ok := got[tt.series]
+		if !ok {
+			t.Errorf("%s: not recorded", tt.series)
+			continue
+		}
+		if h.GetSampleCount() != 1 {
+			t.Errorf("%s: got %d samples, want 1", tt.series, h.GetSampleCount())
+		}
+		if (h.GetSampleSum() > 0) != tt.nonZero {
+			t.Errorf("%s: got size %v", tt.series, h.GetSampleSum())
+		}
+		// byte buckets, not seconds
+		if b := h.GetBucket(); len(b) == 0 || b[0].GetUpperBound() != 64 {
+			t.Errorf("%s: got buckets %v", tt.series, b)
+		}
+	}
+}
+
+func TestCountingBody(t *testing.T) {
+	for _, tt := range []struct {
+		testName string
+		read     func(io.ReadCloser)
+		want     []int64
+	}{
+		{
+			testName: "read to the end",
+			read: func(b io.ReadCloser) {
+				io.ReadAll(b)
+				b.Close()
+			},
+			want: []int64{11},
+		},
+		{
+			testName: "closed early",
+			read: func(b io.ReadCloser) {
+				b.Read(make([]byte, 4))
+				b.Close()
+			},
+			want: []int64{4},
+		},
+	} {
+		t.Run(tt.testName, func(t *testing.T) {
+			var got []int64
+			b := &countingBody{
+				ReadCloser: io.NopCloser(strings.NewReader("hello world")),
+				record:     func(n int64) { got = append(got, n) },
+			}
+			tt.read(b)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("recorded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package callmetrics
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ResourceKey is the attribute key for the name of the resource, such
+// as the S3 bucket or DynamoDB table, an operation is on.
+const ResourceKey = "aws.resource"
+
+// resourceFields are the input fields which name resources.
+var resourceFields = []string{"Bucket", "TableName"}
+
+// ResourceOptions bounds the resource names [ResourceNames] exports.
+// Names in Allow are kept, and then the MaxNames busiest other names
+// seen so far, as estimated by counting them in limited space. The
+// rest are hashed into one of HashBuckets labels such as "hash-3", or
+// are labelled [Other] if there are no buckets.
+type ResourceOptions struct {
+	Allow       []string
+	MaxNames    int
+	HashBuckets int
+}
+
+// ResourceNames returns an API option which finds the name of the
+// resource each operation is on, from the Bucket or TableName field
+// of its input, and adds it to the metrics recorded during the
+// operation as the [ResourceKey] attribute. Operations on no single
+// resource have an empty name.
+//
+// The name is added to the metrics recorded by this package. For
+// the AWS SDK's own metrics, it's available from the context with
+// [ResourceName] for a meter provider to add, such as with a
+// smithyprom.ContextLabel; the OTEL adapter can't. The SDK records
+// the overall call duration outside of the middleware stack, where
+// it isn't available, and its connection-pool metrics aren't
+// recorded per operation, so neither should have it.
+//
+// opts may be nil, which keeps the busiest 20 names and hashes the
+// rest into 8 labels.
+func ResourceNames(opts *ResourceOptions) func(*middleware.Stack) error {
+	if opts == nil {
+		opts = &ResourceOptions{MaxNames: 20, HashBuckets: 8}
+	}
+	m := &resourceNames{names: newBoundedLabel(opts.Allow, opts.MaxNames, opts.HashBuckets)}
+	return func(s *middleware.Stack) error {
+		// before the other initialize middleware, so that they
+		// see the name
+		return s.Initialize.Add(m, middleware.Before)
+	}
+}
+
+type resourceNames struct {
+	names *boundedLabel
+}
+
+type resourceNameKey struct{}
+
+// ResourceName returns the name of the resource the operation in ctx
+// is on, as bounded by [ResourceNames], or "" if there is none.
+func ResourceName(ctx context.Context) string {
+	name, _ := middleware.GetStackValue(ctx, resourceNameKey{}).(string)
+	return name
+}
+
+// resourceName is [ResourceName], and whether [ResourceNames] is
+// installed.
+func resourceName(ctx context.Context) (string, bool) {
+	name, ok := middleware.GetStackValue(ctx, resourceNameKey{}).(string)
+	return name, ok
+}
+
+// ID implements middleware.InitializeMiddleware.
+func (m *resourceNames) ID() string {
+	return "callmetrics.ResourceNames"
+}
+
+// HandleInitialize implements middleware.InitializeMiddleware.
+func (m *resourceNames) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+	middleware.InitializeOutput, middleware.Metadata, error,
+) {
+	var name string
+	if n := inputResource(in.Parameters); n != "" {
+		name = m.names.value(n)
+	}
+	ctx = middleware.WithStackValue(ctx, resourceNameKey{}, name)
+	return next.HandleInitialize(ctx, in)
+}
+
+// inputResource returns the resource an operation's input names, or
+// "" if it names none. Inputs are pointers to structs, and resource
+// names are *string fields.
+func inputResource(params any) string {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	v = v.Elem()
+	for _, name := range resourceFields {
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			continue
+		}
+		if s, ok := f.Interface().(*string); ok && s != nil {
+			return *s
+		}
+	}
+	return ""
+}
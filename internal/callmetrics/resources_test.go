@@ -0,0 +1,70 @@
+package callmetrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestResourceNames(t *testing.T) {
+	cfg, reg, mp := setup(t)
+	responses, err := Responses(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIOptions = append(cfg.APIOptions, responses, ResourceNames(&ResourceOptions{Allow: []string{"demo-table"}, HashBuckets: 4}))
+
+	ctx := context.Background()
+	ddb := dynamodb.NewFromConfig(cfg)
+	key := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}}
+	for _, table := range []string{"demo-table", "demo-sessions", "no-such-table"} {
+		ddb.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(table), Key: key})
+	}
+	ddb.ListTables(ctx, &dynamodb.ListTablesInput{})
+
+	// the stand-in doesn't implement bucket operations, but they
+	// still have responses
+	s3c := s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = true })
+	s3c.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("demo-table")})
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_responses_total The number of operations, by the HTTP status class and AWS error code they ended with
+# TYPE client_call_responses_total counter
+client_call_responses_total{aws_error_code="",aws_resource="",http_status_class="2xx",rpc_method="ListTables",rpc_service="DynamoDB"} 1
+client_call_responses_total{aws_error_code="",aws_resource="demo-table",http_status_class="2xx",rpc_method="GetItem",rpc_service="DynamoDB"} 1
+client_call_responses_total{aws_error_code="",aws_resource="hash-0",http_status_class="2xx",rpc_method="GetItem",rpc_service="DynamoDB"} 1
+client_call_responses_total{aws_error_code="NotImplemented",aws_resource="demo-table",http_status_class="5xx",rpc_method="HeadBucket",rpc_service="S3"} 1
+client_call_responses_total{aws_error_code="ResourceNotFoundException",aws_resource="hash-3",http_status_class="4xx",rpc_method="GetItem",rpc_service="DynamoDB"} 1
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInputResource(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    any
+		want     string
+	}{
+		{testName: "bucket", input: &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}, want: "b"},
+		{testName: "table", input: &dynamodb.QueryInput{TableName: aws.String("t")}, want: "t"},
+		{testName: "unset", input: &dynamodb.QueryInput{}},
+		{testName: "no resource", input: &s3.ListBucketsInput{}},
+		{testName: "nil", input: (*s3.GetObjectInput)(nil)},
+		{testName: "not a pointer", input: s3.GetObjectInput{Bucket: aws.String("b")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if got := inputResource(tt.input); got != tt.want {
+				t.Errorf("inputResource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Package callmetrics provides smithy middleware recording AWS API
+// call metrics which the AWS SDK's built-in metrics leave out.
+//
+// Each middleware is returned as an API option, for
+// [aws.Config.APIOptions] or a client's Options.APIOptions, and
+// records to any [metrics.MeterProvider], so that it can be used with
+// both the Prometheus-native and OTEL meter providers.
+package callmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// scope is the meter scope instruments are created under.
+const scope = "demo/internal/callmetrics"
+
+// Responses returns an API option which counts each operation's
+// responses, by HTTP status class ("2xx", "4xx", ...) and AWS error
+// code (such as "ThrottlingException"). Retries aren't counted: it's
+// the response the operation ends with which is.
+//
+// Operations which end without a response, such as those whose
+// connections were reset, have an empty status class, and those which
+// succeed have an empty error code.
+func Responses(mp metrics.MeterProvider) (func(*middleware.Stack) error, error) {
+	c, err := mp.Meter(scope).Int64Counter("client.call.responses",
+		func(o *metrics.InstrumentOptions) {
+			o.UnitLabel = "{response}"
+			o.Description = "The number of operations, by the HTTP status class and AWS error code they ended with"
+		})
+	if err != nil {
+		return nil, fmt.Errorf("creating responses counter: %w", err)
+	}
+
+	m := &responses{counter: c}
+	return func(s *middleware.Stack) error {
+		return s.Initialize.Add(m, middleware.After)
+	}, nil
+}
+
+type responses struct {
+	counter metrics.Int64Counter
+}
+
+// ID implements middleware.InitializeMiddleware.
+func (m *responses) ID() string {
+	return "callmetrics.Responses"
+}
+
+// HandleInitialize implements middleware.InitializeMiddleware.
+func (m *responses) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+	middleware.InitializeOutput, middleware.Metadata, error,
+) {
+	out, md, err := next.HandleInitialize(ctx, in)
+
+	m.counter.Add(ctx, 1, withOperation(ctx), func(o *metrics.RecordMetricOptions) {
+		o.Properties.Set("http.status_class", statusClass(md, err))
+		o.Properties.Set("aws.error_code", errorCode(err))
+	})
+
+	return out, md, err
+}
+
+// statusClass returns the class of the HTTP status an operation ended
+// with, or "" if there was no response.
+func statusClass(md middleware.Metadata, err error) string {
+	var status int
+	var re *smithyhttp.ResponseError
+	if errors.As(err, &re) {
+		status = re.HTTPStatusCode()
+	} else if rsp, ok := awsmiddleware.GetRawResponse(md).(*smithyhttp.Response); ok {
+		status = rsp.StatusCode
+	}
+	if status == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// errorCode returns the AWS error code of err, or "" if it has none.
+func errorCode(err error) string {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		return ae.ErrorCode()
+	}
+	return ""
+}
+
+// withOperation records the service and operation, under the same
+// attribute keys as the AWS SDK's own metrics, and the resource if
+// [ResourceNames] is installed.
+func withOperation(ctx context.Context) metrics.RecordMetricOption {
+	return func(o *metrics.RecordMetricOptions) {
+		o.Properties.Set("rpc.service", awsmiddleware.GetServiceID(ctx))
+		o.Properties.Set("rpc.method", awsmiddleware.GetOperationName(ctx))
+		if name, ok := resourceName(ctx); ok {
+			o.Properties.Set(ResourceKey, name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package callmetrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"demo/internal/fakeaws"
+	"demo/internal/smithyprom"
+)
+
+// setup starts a stand-in for AWS returning faults in order, and
+// returns a config for it which records to the returned registry.
+func setup(t *testing.T, script ...fakeaws.Fault) (aws.Config, *prometheus.Registry, *smithyprom.MeterProvider) {
+	t.Helper()
+
+	s, err := fakeaws.Start(&fakeaws.Options{Faults: fakeaws.Faults{Script: script}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	var cfg aws.Config
+	s.Configure(&cfg)
+	cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+
+	reg := prometheus.NewRegistry()
+	mp := smithyprom.NewMeterProvider(&smithyprom.Options{Registry: reg, OnError: func(err error) { t.Error(err) }})
+	return cfg, reg, mp
+}
+
+func TestResponses(t *testing.T) {
+	cfg, reg, mp := setup(t, fakeaws.FaultNone, fakeaws.FaultThrottle, fakeaws.FaultServerError, fakeaws.FaultThrottle, fakeaws.FaultReset)
+	responses, err := Responses(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIOptions = append(cfg.APIOptions, responses)
+
+	ctx := context.Background()
+	s3c := s3.NewFromConfig(cfg)
+	for range 3 {
+		s3c.ListBuckets(ctx, &s3.ListBucketsInput{})
+	}
+	ddb := dynamodb.NewFromConfig(cfg)
+	for range 2 {
+		ddb.ListTables(ctx, &dynamodb.ListTablesInput{})
+	}
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_responses_total The number of operations, by the HTTP status class and AWS error code they ended with
+# TYPE client_call_responses_total counter
+client_call_responses_total{aws_error_code="",http_status_class="",rpc_method="ListTables",rpc_service="DynamoDB"} 1
+client_call_responses_total{aws_error_code="",http_status_class="2xx",rpc_method="ListBuckets",rpc_service="S3"} 1
+client_call_responses_total{aws_error_code="InternalError",http_status_class="5xx",rpc_method="ListBuckets",rpc_service="S3"} 1
+client_call_responses_total{aws_error_code="SlowDown",http_status_class="5xx",rpc_method="ListBuckets",rpc_service="S3"} 1
+client_call_responses_total{aws_error_code="ThrottlingException",http_status_class="4xx",rpc_method="ListTables",rpc_service="DynamoDB"} 1
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package exposition writes gathered Prometheus metrics, either once
+// or over HTTP, in the Prometheus text, OpenMetrics text or protobuf
+// formats.
+//
+// The OpenMetrics text format is written with unit metadata and
+// _created lines, which promhttp leaves out. Created timestamps are
+// what let Prometheus detect counter resets of short-lived processes.
+package exposition
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// Formats are the names accepted by [ParseFormat].
+var Formats = []string{"text", "openmetrics", "protobuf"}
+
+// ParseFormat returns the format named s, which is one of [Formats].
+// Names are written as they are, so that UTF-8 names are kept.
+func ParseFormat(s string) (expfmt.Format, error) {
+	var f expfmt.Format
+	switch s {
+	case "text":
+		f = expfmt.NewFormat(expfmt.TypeTextPlain)
+	case "openmetrics":
+		f = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	case "protobuf":
+		f = expfmt.NewFormat(expfmt.TypeProtoDelim)
+	default:
+		return "", fmt.Errorf("unknown format %q, want one of %q", s, Formats)
+	}
+	return f.WithEscapingScheme(model.NoEscaping), nil
+}
+
+// Write gathers metrics from g and writes them to w in format f.
+func Write(w io.Writer, g prometheus.Gatherer, f expfmt.Format) error {
+	mfs, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+
+	enc := expfmt.NewEncoder(w, f, expfmt.WithCreatedLines(), expfmt.WithUnit())
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return fmt.Errorf("encoding %s: %w", mf.GetName(), err)
+		}
+	}
+
+	// OpenMetrics ends with "# EOF"
+	if c, ok := enc.(expfmt.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("encoding: %w", err)
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP handler which serves metrics from g in
+// whichever format the scraper asks for.
+func Handler(g prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		// buffered, so that errors can still be reported
+		var buf bytes.Buffer
+		if err := Write(&buf, g, f); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", string(f))
+		buf.WriteTo(w)
+	})
+}
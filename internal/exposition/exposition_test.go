@@ -0,0 +1,122 @@
+package exposition
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"demo/internal/smithyprom"
+)
+
+func gatherer(t *testing.T) prometheus.Gatherer {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	mp := smithyprom.NewMeterProvider(&smithyprom.Options{Registry: reg, Namespace: "aws"})
+	h, err := mp.Meter("test").Float64Histogram("client.call.duration", func(o *metrics.InstrumentOptions) {
+		o.UnitLabel = "s"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Record(context.Background(), 0.1)
+	return mp.Gatherer(reg)
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{
+			format: "text",
+			want:   []string{"# TYPE aws_client_call_duration_seconds histogram\n"},
+		},
+		{
+			format: "openmetrics",
+			want: []string{
+				"# UNIT aws_client_call_duration_seconds seconds\n",
+				"\naws_client_call_duration_seconds_created ",
+				"# EOF\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			f, err := ParseFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, gatherer(t), f); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("missing %q in:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteProtobuf(t *testing.T) {
+	f, err := ParseFormat("protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, gatherer(t), f); err != nil {
+		t.Fatal(err)
+	}
+
+	var mf dto.MetricFamily
+	if err := expfmt.NewDecoder(&buf, f).Decode(&mf); err != nil {
+		t.Fatal(err)
+	}
+	if got := mf.GetUnit(); got != "seconds" {
+		t.Errorf("got unit %q, want %q", got, "seconds")
+	}
+	if mf.Metric[0].GetHistogram().GetCreatedTimestamp() == nil {
+		t.Error("no created timestamp")
+	}
+}
+
+func TestParseFormatError(t *testing.T) {
+	if _, err := ParseFormat("json"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler(gatherer(t)))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if got := rsp.Header.Get("Content-Type"); !strings.HasPrefix(got, "application/openmetrics-text;") {
+		t.Errorf("got content type %q, want OpenMetrics", got)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(rsp.Body)
+	if !strings.Contains(buf.String(), "# UNIT aws_client_call_duration_seconds seconds\n") {
+		t.Errorf("missing unit in:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,104 @@
+package fakeaws
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const dynamoDBTargetPrefix = "DynamoDB_20120810."
+
+// the table-list we hand back for ListTables
+var dynamoDBTables = []string{"demo-table", "demo-sessions"}
+
+func serveDynamoDB(w http.ResponseWriter, r *http.Request, op string) {
+	switch op {
+	case "ListTables":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"TableNames": dynamoDBTables,
+		})
+	case "ListGlobalTables":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"GlobalTables": []any{},
+		})
+	case "GetItem", "PutItem", "Query":
+		serveDynamoDBItems(w, r, op)
+	default:
+		writeDynamoDBError(w, http.StatusBadRequest, "UnknownOperationException", "fakeaws does not implement "+op)
+	}
+}
+
+// dynamoDBItemsInput is what we read of item operations' input.
+type dynamoDBItemsInput struct {
+	TableName              string
+	IndexName              string
+	ReturnConsumedCapacity string
+}
+
+// serveDynamoDBItems serves item operations on an empty table. Every
+// read consumes half a capacity unit, and every write one, which is
+// reported if asked for.
+func serveDynamoDBItems(w http.ResponseWriter, r *http.Request, op string) {
+	var in dynamoDBItemsInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeDynamoDBError(w, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+	if !slices.Contains(dynamoDBTables, in.TableName) {
+		writeDynamoDBError(w, http.StatusBadRequest, "ResourceNotFoundException", "Requested resource not found")
+		return
+	}
+
+	out := map[string]any{}
+	switch op {
+	case "GetItem":
+		out["Item"] = map[string]any{}
+	case "Query":
+		out["Items"] = []any{}
+		out["Count"] = 0
+		out["ScannedCount"] = 0
+	}
+
+	units, kind := 0.5, "ReadCapacityUnits"
+	if op == "PutItem" {
+		units, kind = 1, "WriteCapacityUnits"
+	}
+	consumed := map[string]any{"TableName": in.TableName, "CapacityUnits": units, kind: units}
+	switch in.ReturnConsumedCapacity {
+	case "INDEXES":
+		capacity := map[string]any{"CapacityUnits": units, kind: units}
+		if in.IndexName != "" {
+			consumed["GlobalSecondaryIndexes"] = map[string]any{in.IndexName: capacity}
+		} else {
+			consumed["Table"] = capacity
+		}
+		fallthrough
+	case "TOTAL":
+		out["ConsumedCapacity"] = consumed
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeDynamoDBError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]any{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + code,
+		"message": message,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amzn-Requestid", requestID)
+	// the SDK validates this whether or not we send it
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(b)), 10))
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -0,0 +1,206 @@
+package fakeaws
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Fault is a failure the server can return in place of
+// a normal response.
+type Fault int
+
+const (
+	// FaultNone means the request is answered normally.
+	FaultNone Fault = iota
+	// FaultThrottle returns the service's throttling error
+	// ("SlowDown" for S3, "ThrottlingException" for DynamoDB).
+	FaultThrottle
+	// FaultServerError returns an HTTP 500.
+	FaultServerError
+	// FaultReset resets the connection without responding.
+	FaultReset
+)
+
+var faultNames = map[Fault]string{
+	FaultNone:        "ok",
+	FaultThrottle:    "throttle",
+	FaultServerError: "5xx",
+	FaultReset:       "reset",
+}
+
+func (f Fault) String() string {
+	if s, ok := faultNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// ParseFault parses the name of a fault, as returned by [Fault.String].
+func ParseFault(s string) (Fault, error) {
+	for f, name := range faultNames {
+		if name == s {
+			return f, nil
+		}
+	}
+	return FaultNone, fmt.Errorf("unknown fault %q", s)
+}
+
+// ParseScript parses a comma-separated list of faults, such
+// as "throttle,throttle,ok,reset".
+func ParseScript(s string) ([]Fault, error) {
+	var script []Fault
+	for _, name := range strings.Split(s, ",") {
+		f, err := ParseFault(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		script = append(script, f)
+	}
+	return script, nil
+}
+
+// Faults configures which failures the server injects.
+//
+// Requests are first answered from Script, in order, one
+// entry per request. Once the script is used up each
+// request fails with the given probabilities.
+//
+// While a reset may be coming, responses close their
+// connections, as net/http silently retries requests whose
+// reused connections are reset.
+type Faults struct {
+	Script []Fault
+
+	// probabilities, from 0 to 1
+	ThrottleRate    float64
+	ServerErrorRate float64
+	ResetRate       float64
+
+	// Latency is added to every response.
+	Latency time.Duration
+}
+
+// RegisterFlags registers command-line flags which populate f.
+func (f *Faults) RegisterFlags(fs *flag.FlagSet) {
+	rateVar(fs, &f.ThrottleRate, "fault-throttle", "probability of a throttling error from the local stand-in")
+	rateVar(fs, &f.ServerErrorRate, "fault-5xx", "probability of a 5xx error from the local stand-in")
+	rateVar(fs, &f.ResetRate, "fault-reset", "probability of a connection reset from the local stand-in")
+	fs.DurationVar(&f.Latency, "fault-latency", 0, "latency added to every response from the local stand-in")
+	fs.Func("fault-script", "comma-separated faults (ok, throttle, 5xx, reset) for the local stand-in to return in order", func(s string) error {
+		script, err := ParseScript(s)
+		if err != nil {
+			return err
+		}
+		f.Script = script
+		return nil
+	})
+}
+
+// rateVar registers a flag for a probability, from 0 to 1.
+func rateVar(fs *flag.FlagSet, p *float64, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		if err := checkRate(v); err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	})
+}
+
+func checkRate(v float64) error {
+	if !(v >= 0 && v <= 1) {
+		return fmt.Errorf("probability %v is not from 0 to 1", v)
+	}
+	return nil
+}
+
+// validate checks the probabilities are from 0 to 1, and
+// that they add up to no more than 1.
+func (f *Faults) validate() error {
+	for _, v := range []float64{f.ThrottleRate, f.ServerErrorRate, f.ResetRate} {
+		if err := checkRate(v); err != nil {
+			return err
+		}
+	}
+	if sum := f.ThrottleRate + f.ServerErrorRate + f.ResetRate; sum > 1 {
+		return fmt.Errorf("fault probabilities add up to %v, more than 1", sum)
+	}
+	return nil
+}
+
+// faultInjector picks the fault for each request.
+type faultInjector struct {
+	mu     sync.Mutex
+	faults Faults
+}
+
+func (i *faultInjector) next() Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.faults.Script) > 0 {
+		f := i.faults.Script[0]
+		i.faults.Script = i.faults.Script[1:]
+		return f
+	}
+
+	p := rand.Float64()
+	for _, c := range []struct {
+		rate  float64
+		fault Fault
+	}{
+		{i.faults.ThrottleRate, FaultThrottle},
+		{i.faults.ServerErrorRate, FaultServerError},
+		{i.faults.ResetRate, FaultReset},
+	} {
+		if p < c.rate {
+			return c.fault
+		}
+		p -= c.rate
+	}
+
+	return FaultNone
+}
+
+// mayReset returns whether a later request may be reset.
+func (i *faultInjector) mayReset() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.faults.ResetRate > 0 || slices.Contains(i.faults.Script, FaultReset)
+}
+
+func (i *faultInjector) latency() time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.faults.Latency
+}
+
+// resetConnection drops the client connection without
+// sending a response. The client sees ECONNRESET.
+func resetConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		// a zero linger makes Close send RST instead of FIN
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,154 @@
+package fakeaws
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+func TestParseScript(t *testing.T) {
+	got, err := ParseScript("throttle, 5xx,reset,ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Fault{FaultThrottle, FaultServerError, FaultReset, FaultNone}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseScript() = %v, want %v", got, want)
+	}
+
+	_, err = ParseScript("throttle,oops")
+	if err == nil {
+		t.Error("expected an error for an unknown fault")
+	}
+}
+
+func TestScriptedFaults(t *testing.T) {
+	tests := []struct {
+		testName string
+		fault    Fault
+		s3Code   string
+		ddbCode  string
+	}{
+		{
+			testName: "throttle",
+			fault:    FaultThrottle,
+			s3Code:   "SlowDown",
+			ddbCode:  "ThrottlingException",
+		},
+		{
+			testName: "server error",
+			fault:    FaultServerError,
+			s3Code:   "InternalError",
+			ddbCode:  "InternalServerError",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s, cfg := startServer(t)
+			s.SetFaults(Faults{Script: []Fault{tt.fault, tt.fault}})
+
+			// no retries, so we see each fault
+			cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+
+			_, err := s3.NewFromConfig(cfg).ListBuckets(context.Background(), &s3.ListBucketsInput{})
+			if code := errorCode(err); code != tt.s3Code {
+				t.Errorf("s3 error code = %q, want %q", code, tt.s3Code)
+			}
+
+			_, err = dynamodb.NewFromConfig(cfg).ListTables(context.Background(), &dynamodb.ListTablesInput{})
+			if code := errorCode(err); code != tt.ddbCode {
+				t.Errorf("dynamodb error code = %q, want %q", code, tt.ddbCode)
+			}
+		})
+	}
+}
+
+func TestFaultsAreRetried(t *testing.T) {
+	s, cfg := startServer(t)
+	s.SetFaults(Faults{Script: []Fault{FaultThrottle, FaultReset}})
+
+	// the default retryer gives us three attempts
+	_, err := dynamodb.NewFromConfig(cfg).ListTables(context.Background(), &dynamodb.ListTablesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// net/http silently retries idempotent requests on reused connections
+// which are reset, which would skip over a scripted reset
+func TestScriptedResetOnKeepAlive(t *testing.T) {
+	s, cfg := startServer(t)
+	s.SetFaults(Faults{Script: []Fault{FaultNone, FaultReset, FaultThrottle}})
+	cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+
+	client := s3.NewFromConfig(cfg)
+	var codes []string
+	for range 3 {
+		_, err := client.ListBuckets(context.Background(), &s3.ListBucketsInput{})
+		codes = append(codes, errorCode(err))
+		if len(codes) == 2 && err == nil {
+			t.Fatal("the scripted reset didn't fail the request")
+		}
+	}
+	if want := []string{"", "", "SlowDown"}; !slices.Equal(codes, want) {
+		t.Errorf("got error codes %q, want %q", codes, want)
+	}
+}
+
+func TestFaultRates(t *testing.T) {
+	s, cfg := startServer(t)
+	s.SetFaults(Faults{ServerErrorRate: 1})
+	cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+
+	client := dynamodb.NewFromConfig(cfg)
+	for range 5 {
+		_, err := client.ListTables(context.Background(), &dynamodb.ListTablesInput{})
+		if code := errorCode(err); code != "InternalServerError" {
+			t.Fatalf("error code = %q, want %q", code, "InternalServerError")
+		}
+	}
+}
+
+func TestInvalidFaultRates(t *testing.T) {
+	for _, f := range []Faults{
+		{ThrottleRate: 1.5},
+		{ResetRate: -0.1},
+		{ThrottleRate: 0.6, ServerErrorRate: 0.6},
+	} {
+		if _, err := Start(&Options{Faults: f}); err == nil {
+			t.Errorf("Start(%+v): expected an error", f)
+		}
+	}
+
+	s, _ := startServer(t)
+	if err := s.SetFaults(Faults{ServerErrorRate: 2}); err == nil {
+		t.Error("SetFaults: expected an error")
+	}
+
+	var f Faults
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	f.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-fault-reset", "2"}); err == nil {
+		t.Error("parsing flags: expected an error")
+	}
+	if err := fs.Parse([]string{"-fault-reset", "0.5"}); err != nil || f.ResetRate != 0.5 {
+		t.Errorf("parsing flags: got %v, rate %v", err, f.ResetRate)
+	}
+}
+
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
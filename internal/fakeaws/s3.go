@@ -0,0 +1,79 @@
+package fakeaws
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// the bucket-list we hand back for ListBuckets
+var s3Buckets = []s3Bucket{
+	{Name: "demo-bucket", CreationDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	{Name: "demo-logs", CreationDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+type s3Bucket struct {
+	Name         string    `xml:"Name"`
+	CreationDate time.Time `xml:"CreationDate"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func serveS3(w http.ResponseWriter, r *http.Request) {
+	// ListBuckets is a GET on the service root. We don't need to
+	// tell it apart from anything else yet.
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		writeXML(w, http.StatusOK, &s3ListAllMyBucketsResult{
+			XMLNS: s3Namespace,
+			Owner: s3Owner{
+				ID:          "fakeaws",
+				DisplayName: "fakeaws",
+			},
+			Buckets: s3Buckets,
+		})
+		return
+	}
+
+	writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "fakeaws does not implement "+r.Method+" "+r.URL.Path)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string, message string) {
+	writeXML(w, status, &s3Error{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+	})
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("X-Amz-Request-Id", requestID)
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
@@ -0,0 +1,160 @@
+// Package fakeaws provides a local stand-in for the handful of
+// S3 and DynamoDB APIs the demos call, so that the metrics pipeline
+// can be exercised without network access or AWS credentials.
+//
+// It only speaks enough of the S3 REST-XML and DynamoDB JSON 1.0
+// protocols to answer the calls the demos make.
+package fakeaws
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// we don't bother generating unique request IDs
+const requestID = "fakeaws-request"
+
+// A Server is a running fake AWS endpoint.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	faults   faultInjector
+}
+
+// Options configures a [Server].
+type Options struct {
+	Faults Faults
+}
+
+// Start starts a new fake AWS endpoint listening on a random
+// loopback port. Call [Server.Close] to shut it down.
+//
+// opts may be nil.
+func Start(opts *Options) (*Server, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := opts.Faults.validate(); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: l,
+	}
+	s.SetFaults(opts.Faults)
+	s.server = &http.Server{
+		Handler: s,
+	}
+
+	go func() {
+		// Serve returns ErrServerClosed once we're shut down,
+		// and there's nobody to tell about anything else.
+		_ = s.server.Serve(l)
+	}()
+
+	return s, nil
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// Close shuts down the server.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Configure points all clients created from cfg at the server,
+// using static credentials.
+func (s *Server) Configure(cfg *aws.Config) {
+	cfg.BaseEndpoint = aws.String(s.URL())
+	cfg.Region = "us-east-1"
+	cfg.Credentials = credentials.NewStaticCredentialsProvider("AKIDFAKEAWS", "fakeaws", "")
+}
+
+// SetFaults replaces the faults the server injects. It returns an
+// error, and leaves them as they were, if a probability is invalid.
+func (s *Server) SetFaults(f Faults) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+
+	f.Script = slices.Clone(f.Script)
+	s.faults.faults = f
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// DynamoDB is JSON-RPC-ish and always identifies the
+	// operation in a header. Everything else we assume is S3.
+	target := r.Header.Get("X-Amz-Target")
+
+	if d := s.faults.latency(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	fault := s.faults.next()
+	if s.faults.mayReset() {
+		// so that the next request is on a new connection,
+		// which net/http doesn't retry if it's reset
+		w.Header().Set("Connection", "close")
+	}
+
+	switch fault {
+	case FaultThrottle:
+		if target != "" {
+			writeDynamoDBError(w, http.StatusBadRequest, "ThrottlingException", "Rate of requests exceeds the allowed throughput.")
+		} else {
+			writeS3Error(w, http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.")
+		}
+		return
+	case FaultServerError:
+		if target != "" {
+			writeDynamoDBError(w, http.StatusInternalServerError, "InternalServerError", "Internal server error")
+		} else {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again.")
+		}
+		return
+	case FaultReset:
+		resetConnection(w)
+		return
+	}
+
+	if target != "" {
+		op, ok := strings.CutPrefix(target, dynamoDBTargetPrefix)
+		if !ok {
+			writeDynamoDBError(w, http.StatusBadRequest, "UnknownOperationException", "unknown target "+target)
+			return
+		}
+		serveDynamoDB(w, r, op)
+		return
+	}
+
+	serveS3(w, r)
+}
@@ -0,0 +1,126 @@
+package fakeaws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func startServer(t *testing.T) (*Server, aws.Config) {
+	t.Helper()
+
+	s, err := Start(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	var cfg aws.Config
+	s.Configure(&cfg)
+	return s, cfg
+}
+
+func TestListBuckets(t *testing.T) {
+	_, cfg := startServer(t)
+	client := s3.NewFromConfig(cfg)
+
+	out, err := client.ListBuckets(context.Background(), &s3.ListBucketsInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, b := range out.Buckets {
+		got = append(got, aws.ToString(b.Name))
+	}
+	want := []string{"demo-bucket", "demo-logs"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListBuckets() = %v, want %v", got, want)
+	}
+}
+
+func TestListTables(t *testing.T) {
+	_, cfg := startServer(t)
+	client := dynamodb.NewFromConfig(cfg)
+
+	out, err := client.ListTables(context.Background(), &dynamodb.ListTablesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"demo-table", "demo-sessions"}
+	if !slices.Equal(out.TableNames, want) {
+		t.Errorf("ListTables() = %v, want %v", out.TableNames, want)
+	}
+
+	_, err = client.ListGlobalTables(context.Background(), &dynamodb.ListGlobalTablesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestItems(t *testing.T) {
+	_, cfg := startServer(t)
+	client := dynamodb.NewFromConfig(cfg)
+	ctx := context.Background()
+
+	get, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String("demo-table"),
+		Key:                    map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityIndexes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToFloat64(get.ConsumedCapacity.Table.ReadCapacityUnits); got != 0.5 {
+		t.Errorf("GetItem() consumed %v read units, want 0.5", got)
+	}
+
+	query, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String("demo-table"),
+		IndexName:              aws.String("by-owner"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityIndexes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToFloat64(query.ConsumedCapacity.GlobalSecondaryIndexes["by-owner"].CapacityUnits); got != 0.5 {
+		t.Errorf("Query() consumed %v index units, want 0.5", got)
+	}
+
+	put, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("demo-table"),
+		Item:      map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if put.ConsumedCapacity != nil {
+		t.Errorf("PutItem() consumed capacity %v without asking for it", put.ConsumedCapacity)
+	}
+
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("no-such-table"),
+		Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}},
+	})
+	var rnf *types.ResourceNotFoundException
+	if !errors.As(err, &rnf) {
+		t.Errorf("GetItem() on a missing table: got %v, want ResourceNotFoundException", err)
+	}
+}
+
+func TestUnknownOperation(t *testing.T) {
+	_, cfg := startServer(t)
+	client := dynamodb.NewFromConfig(cfg)
+
+	_, err := client.DescribeLimits(context.Background(), &dynamodb.DescribeLimitsInput{})
+	if err == nil {
+		t.Fatal("expected an error for an unimplemented operation")
+	}
+}
@@ -0,0 +1,126 @@
+// Package otelexport wires the OTEL metrics SDK to a Prometheus
+// registry, for use with the smithy-go OTEL adapter.
+package otelexport
+
+import (
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures [NewMeterProvider].
+type Options struct {
+	// ScopeInfo adds the otel_scope_* labels to every metric.
+	//
+	// The AWS SDK creates a meter per service client, so metrics
+	// which aren't labelled by service (such as the HTTP client
+	// metrics) collide when more than one client shares a
+	// registry unless the scope is included.
+	ScopeInfo bool
+
+	// WithoutUnits and WithoutCounterSuffixes omit the unit and
+	// _total suffixes from metric names.
+	WithoutUnits           bool
+	WithoutCounterSuffixes bool
+
+	Views []sdkmetric.View
+}
+
+// NewMeterProvider creates an OTEL meter-provider whose back-end is the
+// provided Prometheus registry.
+//
+// opts may be nil.
+func NewMeterProvider(promRegistry prometheus.Registerer, opts *Options) (*sdkmetric.MeterProvider, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	exporterOpts := []otelprom.Option{
+		otelprom.WithNamespace("aws"),
+		otelprom.WithoutTargetInfo(),
+		otelprom.WithRegisterer(promRegistry),
+
+		// OTEL default buckets assume you're using milliseconds. Substitute defaults
+		// appropriate for units of seconds.
+		//
+		// https://github.com/open-telemetry/opentelemetry-go/issues/5821
+		otelprom.WithAggregationSelector(func(ik sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+			switch ik {
+			case sdkmetric.InstrumentKindHistogram:
+				return sdkmetric.AggregationExplicitBucketHistogram{
+					Boundaries: prometheus.DefBuckets,
+					NoMinMax:   false,
+				}
+			default:
+				return sdkmetric.DefaultAggregationSelector(ik)
+			}
+		}),
+	}
+	if !opts.ScopeInfo {
+		exporterOpts = append(exporterOpts, otelprom.WithoutScopeInfo())
+	}
+	if opts.WithoutUnits {
+		exporterOpts = append(exporterOpts, otelprom.WithoutUnits())
+	}
+	if opts.WithoutCounterSuffixes {
+		exporterOpts = append(exporterOpts, otelprom.WithoutCounterSuffixes())
+	}
+
+	// create an otel metric-exporter associated with the
+	// provided prometheus registry
+	metricExporter, err := otelprom.New(exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a meter-provider associated with the exporter
+	mpOpts := []sdkmetric.Option{sdkmetric.WithReader(metricExporter)}
+	for _, v := range withByteBuckets(opts.Views) {
+		mpOpts = append(mpOpts, sdkmetric.WithView(v))
+	}
+	return sdkmetric.NewMeterProvider(mpOpts...), nil
+}
+
+// byteBuckets are the default buckets for histograms of bytes, the
+// same as smithyprom.DefByteBuckets.
+var byteBuckets = prometheus.ExponentialBuckets(64, 4, 12)
+
+// withByteBuckets defaults histograms of bytes to byteBuckets. The
+// aggregation selector only knows an instrument's kind, so it's done
+// with views: views are wrapped to fill in the buckets, and another
+// view takes the instruments none of them match. Every matching view
+// produces a stream, so that one mustn't match anything else.
+func withByteBuckets(views []sdkmetric.View) []sdkmetric.View {
+	isBytes := func(i sdkmetric.Instrument) bool {
+		return i.Kind == sdkmetric.InstrumentKindHistogram && i.Unit == "By"
+	}
+	agg := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: byteBuckets}
+
+	wrapped := make([]sdkmetric.View, 0, len(views)+1)
+	for _, v := range views {
+		wrapped = append(wrapped, func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+			s, ok := v(i)
+			if ok && s.Aggregation == nil && isBytes(i) {
+				s.Aggregation = agg
+			}
+			return s, ok
+		})
+	}
+	return append(wrapped, func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		if !isBytes(i) {
+			return sdkmetric.Stream{}, false
+		}
+		for _, v := range views {
+			if _, ok := v(i); ok {
+				return sdkmetric.Stream{}, false
+			}
+		}
+		return sdkmetric.Stream{
+			Name:        i.Name,
+			Description: i.Description,
+			Unit:        i.Unit,
+			Aggregation: agg,
+		}, true
+	})
+}
@@ -0,0 +1,192 @@
+// Package policy loads an instrumentation policy: which AWS SDK
+// instruments are exported and how. The same policy configures both
+// the native Prometheus adapter and the OTEL adapter.
+//
+// Policies are JSON:
+//
+//	{
+//	  "drop": ["client.call.serialization_duration"],
+//	  "buckets": {"client.call.duration": [0.05, 0.1, 0.5, 1, 5]},
+//	  "dropLabels": {"client.call.*": ["rpc.method"]},
+//	  "rename": {"client.call.attempts": "aws.call.attempts"}
+//	}
+//
+// Instrument names (the keys of each section) may use the
+// wildcards of [path.Match], except in "rename".
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+//go:embed default.json
+var defaultPolicy []byte
+
+// A Policy describes which instruments are exported and how.
+type Policy struct {
+	// Drop lists instruments which aren't exported at all.
+	Drop []string `json:"drop"`
+	// Buckets overrides histogram bucket boundaries, by instrument.
+	Buckets map[string][]float64 `json:"buckets"`
+	// DropLabels lists attributes which aren't exported as labels,
+	// by instrument.
+	DropLabels map[string][]string `json:"dropLabels"`
+	// Rename maps instrument names to the name they're exported
+	// as (before translation to a Prometheus name).
+	Rename map[string]string `json:"rename"`
+}
+
+// Default returns the policy the demos use when none is given. It
+// drops instruments which appear to be purely client-side computation.
+func Default() *Policy {
+	p, err := parse(defaultPolicy)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Load reads a policy from a JSON file.
+func Load(filename string) (*Policy, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("loading policy %s: %s", filename, err)
+	}
+	return p, nil
+}
+
+// LoadOrDefault loads the policy in filename, or returns the
+// default policy if filename is empty.
+func LoadOrDefault(filename string) (*Policy, error) {
+	if filename == "" {
+		return Default(), nil
+	}
+	return Load(filename)
+}
+
+func parse(b []byte) (*Policy, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+
+	var p Policy
+	if err := d.Decode(&p); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	patterns := slices.Clone(p.Drop)
+	for k := range p.Buckets {
+		patterns = append(patterns, k)
+	}
+	for k := range p.DropLabels {
+		patterns = append(patterns, k)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad instrument pattern %q: %s", pattern, err)
+		}
+	}
+
+	for from, to := range p.Rename {
+		if isPattern(from) {
+			return fmt.Errorf("cannot rename by wildcard %q", from)
+		}
+		if to == "" {
+			return fmt.Errorf("cannot rename %q to an empty name", from)
+		}
+	}
+
+	for k, buckets := range p.Buckets {
+		if !slices.IsSorted(buckets) {
+			return fmt.Errorf("buckets for %q are not in increasing order", k)
+		}
+	}
+
+	return nil
+}
+
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+func matches(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// A stream is what the policy says about one instrument, independent
+// of which adapter it's for.
+type stream struct {
+	drop       bool
+	name       string
+	buckets    []float64
+	dropLabels []string
+}
+
+// streamFor applies the policy to the named instrument. It returns
+// false if the policy has nothing to say about it.
+func (p *Policy) streamFor(name string) (stream, bool) {
+	var s stream
+	var ok bool
+
+	for _, pattern := range p.Drop {
+		if matches(pattern, name) {
+			return stream{drop: true}, true
+		}
+	}
+
+	if to, found := p.Rename[name]; found {
+		s.name = to
+		ok = true
+	}
+
+	// an exact match wins over patterns, and otherwise the
+	// (lexically) first matching pattern
+	if buckets, found := p.Buckets[name]; found {
+		s.buckets = buckets
+		ok = true
+	} else {
+		for _, pattern := range sortedKeys(p.Buckets) {
+			if matches(pattern, name) {
+				s.buckets = p.Buckets[pattern]
+				ok = true
+				break
+			}
+		}
+	}
+
+	// label drops accumulate
+	for _, pattern := range sortedKeys(p.DropLabels) {
+		if matches(pattern, name) {
+			s.dropLabels = append(s.dropLabels, p.DropLabels[pattern]...)
+			ok = true
+		}
+	}
+
+	return s, ok
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,236 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"demo/internal/otelexport"
+	"demo/internal/smithyprom"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		testName string
+		json     string
+	}{
+		{
+			testName: "unknown field",
+			json:     `{"dorp": ["client.call.duration"]}`,
+		},
+		{
+			testName: "bad pattern",
+			json:     `{"drop": ["client.call.[duration"]}`,
+		},
+		{
+			testName: "wildcard rename",
+			json:     `{"rename": {"client.*": "aws.call"}}`,
+		},
+		{
+			testName: "empty rename",
+			json:     `{"rename": {"client.call.duration": ""}}`,
+		},
+		{
+			testName: "unsorted buckets",
+			json:     `{"buckets": {"client.call.duration": [1, 0.5]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if _, err := parse([]byte(tt.json)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "policy.json")
+	err := os.WriteFile(filename, []byte(`{"drop": ["client.call.*"]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadOrDefault(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(p.Drop, []string{"client.call.*"}) {
+		t.Errorf("loaded %+v", p)
+	}
+
+	p, err = LoadOrDefault("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Drop) != 4 {
+		t.Errorf("default policy = %+v", p)
+	}
+}
+
+func TestStreamFor(t *testing.T) {
+	p := &Policy{
+		Drop: []string{"client.call.*_duration"},
+		Buckets: map[string][]float64{
+			"client.call.duration": {1},
+			"client.*":             {2},
+			"client.http.*":        {3},
+		},
+		DropLabels: map[string][]string{
+			"client.*":             {"rpc.method"},
+			"client.call.attempts": {"rpc.service"},
+		},
+		Rename: map[string]string{
+			"client.call.attempts": "aws.attempts",
+		},
+	}
+
+	tests := []struct {
+		name string
+		want stream
+		ok   bool
+	}{
+		{
+			name: "client.call.serialization_duration",
+			want: stream{drop: true},
+			ok:   true,
+		},
+		{
+			name: "client.call.duration",
+			want: stream{buckets: []float64{1}, dropLabels: []string{"rpc.method"}},
+			ok:   true,
+		},
+		{
+			name: "client.http.do_request_duration",
+			want: stream{buckets: []float64{2}, dropLabels: []string{"rpc.method"}},
+			ok:   true,
+		},
+		{
+			name: "client.call.attempts",
+			want: stream{name: "aws.attempts", buckets: []float64{2}, dropLabels: []string{"rpc.method", "rpc.service"}},
+			ok:   true,
+		},
+		{
+			name: "unrelated",
+			ok:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.streamFor(tt.name)
+			if ok != tt.ok {
+				t.Fatalf("streamFor() ok = %v, want %v", ok, tt.ok)
+			}
+			if got.drop != tt.want.drop || got.name != tt.want.name ||
+				!slices.Equal(got.buckets, tt.want.buckets) || !slices.Equal(got.dropLabels, tt.want.dropLabels) {
+				t.Errorf("streamFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// both adapters should export the same families, with the same
+// labels and buckets, for the same policy
+func TestPolicyAppliesToBothAdapters(t *testing.T) {
+	p, err := parse([]byte(`{
+		"drop": ["client.call.serialization_duration"],
+		"buckets": {"client.call.duration": [0.1, 1]},
+		"dropLabels": {"client.call.*": ["rpc.method"]},
+		"rename": {"client.call.attempts": "aws.call.attempts"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(mp metrics.MeterProvider) {
+		ctx := context.Background()
+		m := mp.Meter("test")
+		attrs := func(o *metrics.RecordMetricOptions) {
+			o.Properties.Set("rpc.service", "S3")
+			o.Properties.Set("rpc.method", "ListBuckets")
+		}
+		unit := func(u string) metrics.InstrumentOption {
+			return func(o *metrics.InstrumentOptions) { o.UnitLabel = u }
+		}
+
+		for _, name := range []string{"client.call.duration", "client.call.serialization_duration"} {
+			h, err := m.Float64Histogram(name, unit("s"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Record(ctx, 0.5, attrs)
+		}
+
+		c, err := m.Int64Counter("client.call.attempts", unit("{attempt}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(ctx, 1, attrs)
+	}
+
+	promRegistry := prometheus.NewRegistry()
+	record(smithyprom.NewMeterProvider(&smithyprom.Options{
+		Registry:  promRegistry,
+		Namespace: "aws",
+		Views:     []smithyprom.View{p.PromView()},
+	}))
+
+	otelRegistry := prometheus.NewRegistry()
+	otelProvider, err := otelexport.NewMeterProvider(otelRegistry, &otelexport.Options{
+		Views: []sdkmetric.View{p.OTELView()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(smithyotelmetrics.Adapt(otelProvider))
+
+	want := []string{
+		`aws_aws_call_attempts_total{rpc_service}`,
+		`aws_client_call_duration_seconds{rpc_service}[0.1 1]`,
+	}
+	for _, reg := range []*prometheus.Registry{promRegistry, otelRegistry} {
+		if got := describeFamilies(t, reg); !slices.Equal(got, want) {
+			t.Errorf("got families %v, want %v", got, want)
+		}
+	}
+}
+
+// describeFamilies summarizes the name, label names and buckets of
+// each family in reg.
+func describeFamilies(t *testing.T, reg prometheus.Gatherer) []string {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, mf := range mfs {
+		s := mf.GetName() + "{"
+		for i, lp := range mf.GetMetric()[0].GetLabel() {
+			if i > 0 {
+				s += ","
+			}
+			s += lp.GetName()
+		}
+		s += "}"
+		if mf.GetType() == dto.MetricType_HISTOGRAM {
+			var bounds []float64
+			for _, b := range mf.GetMetric()[0].GetHistogram().GetBucket() {
+				bounds = append(bounds, b.GetUpperBound())
+			}
+			s += fmt.Sprint(bounds)
+		}
+		got = append(got, s)
+	}
+	return got
+}
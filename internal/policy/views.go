@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"demo/internal/smithyprom"
+)
+
+// Each adapter gets a single view, rather than one per section of the
+// policy, because every matching view produces its own stream.
+
+// PromView returns a view implementing the policy for the native
+// Prometheus adapter.
+func (p *Policy) PromView() smithyprom.View {
+	return func(i smithyprom.Instrument) (smithyprom.Stream, bool) {
+		s, ok := p.streamFor(i.Name)
+		if !ok {
+			return smithyprom.Stream{}, false
+		}
+		if s.drop {
+			return smithyprom.Stream{Aggregation: smithyprom.AggregationDrop{}}, true
+		}
+
+		ps := smithyprom.Stream{
+			Name: s.name,
+		}
+		if s.buckets != nil && i.Kind == smithyprom.InstrumentKindHistogram {
+			ps.Aggregation = smithyprom.AggregationExplicitBucketHistogram{Boundaries: s.buckets}
+		}
+		if len(s.dropLabels) > 0 {
+			ps.AttributeFilter = func(key string) bool {
+				return !slices.Contains(s.dropLabels, key)
+			}
+		}
+		return ps, true
+	}
+}
+
+// OTELView returns a view implementing the policy for the OTEL metrics SDK.
+func (p *Policy) OTELView() sdkmetric.View {
+	return func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		s, ok := p.streamFor(i.Name)
+		if !ok {
+			return sdkmetric.Stream{}, false
+		}
+		if s.drop {
+			return sdkmetric.Stream{Aggregation: sdkmetric.AggregationDrop{}}, true
+		}
+
+		// unlike ours, OTEL views don't fill in defaults
+		out := sdkmetric.Stream{
+			Name:        i.Name,
+			Description: i.Description,
+			Unit:        i.Unit,
+		}
+		if s.name != "" {
+			out.Name = s.name
+		}
+		if s.buckets != nil && i.Kind == sdkmetric.InstrumentKindHistogram {
+			out.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: s.buckets}
+		}
+		if len(s.dropLabels) > 0 {
+			keys := make([]attribute.Key, len(s.dropLabels))
+			for i, l := range s.dropLabels {
+				keys[i] = attribute.Key(l)
+			}
+			out.AttributeFilter = attribute.NewDenyKeysFilter(keys...)
+		}
+		return out, true
+	}
+}
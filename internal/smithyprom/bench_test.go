@@ -0,0 +1,172 @@
+package smithyprom
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"demo/internal/otelexport"
+)
+
+// Benchmarks run against both the native adapter and the OTEL
+// adapter, as sub-benchmarks. See the README for comparing results
+// between commits.
+
+var benchProviders = []struct {
+	name string
+	new  func(b *testing.B) metrics.MeterProvider
+}{
+	{
+		name: "prom",
+		new: func(b *testing.B) metrics.MeterProvider {
+			return NewMeterProvider(&Options{
+				Registry:  prometheus.NewRegistry(),
+				Namespace: "aws",
+			})
+		},
+	},
+	{
+		name: "otel",
+		new: func(b *testing.B) metrics.MeterProvider {
+			mp, err := otelexport.NewMeterProvider(prometheus.NewRegistry(), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return smithyotelmetrics.Adapt(mp)
+		},
+	},
+}
+
+func benchAllProviders(b *testing.B, fn func(b *testing.B, mp metrics.MeterProvider)) {
+	for _, p := range benchProviders {
+		b.Run(p.name, func(b *testing.B) {
+			fn(b, p.new(b))
+		})
+	}
+}
+
+// benchAttrs are the attributes the AWS SDK records with, converted
+// to interfaces up front so the benchmarks measure the adapters
+// rather than the caller.
+var benchAttrs = []metrics.RecordMetricOption{staticAttrs("rpc.service", "S3", "rpc.method", "ListBuckets")}
+
+// the AWS SDK looks instruments up on every call
+func BenchmarkGetInstrument(b *testing.B) {
+	benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+		m := mp.Meter("github.com/aws/aws-sdk-go-v2/service/s3")
+		unit := withUnit("s")
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := m.Float64Histogram("client.call.duration", unit); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// as the AWS SDK records: looking the instrument up for every
+// observation
+func BenchmarkGetInstrumentRecord(b *testing.B) {
+	benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+		m := mp.Meter("github.com/aws/aws-sdk-go-v2/service/s3")
+		unit := withUnit("s")
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h, err := m.Float64Histogram("client.call.duration", unit)
+			if err != nil {
+				b.Fatal(err)
+			}
+			h.Record(ctx, 0.1, benchAttrs...)
+		}
+	})
+}
+
+func BenchmarkCounterAdd(b *testing.B) {
+	tests := []struct {
+		name  string
+		attrs []metrics.RecordMetricOption
+	}{
+		{name: "labeled", attrs: benchAttrs},
+		{name: "unlabeled"},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+				c, err := mp.Meter("test").Int64Counter("client.call.attempts")
+				if err != nil {
+					b.Fatal(err)
+				}
+				ctx := context.Background()
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					c.Add(ctx, 1, tt.attrs...)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkHistogramRecord(b *testing.B) {
+	benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+		h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.Record(ctx, 0.1, benchAttrs...)
+		}
+	})
+}
+
+func BenchmarkRecordParallel(b *testing.B) {
+	// every goroutine records to the same series, or each to its own
+	b.Run("same series", func(b *testing.B) {
+		benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+			h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					h.Record(ctx, 0.1, benchAttrs...)
+				}
+			})
+		})
+	})
+
+	b.Run("distinct series", func(b *testing.B) {
+		benchAllProviders(b, func(b *testing.B, mp metrics.MeterProvider) {
+			h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+
+			var worker atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				attrs := []metrics.RecordMetricOption{
+					staticAttrs("rpc.service", "S3", "rpc.method", "Op"+strconv.FormatInt(worker.Add(1), 10)),
+				}
+				for pb.Next() {
+					h.Record(ctx, 0.1, attrs...)
+				}
+			})
+		})
+	})
+}
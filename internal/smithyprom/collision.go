@@ -0,0 +1,127 @@
+package smithyprom
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+// Translating names to Prometheus conventions is lossy: "a.b", "a_b"
+// and "a-b" all become "a_b". Two instruments, or two attributes of
+// one instrument, which end up with the same name would otherwise
+// panic on registration or have their series silently merged.
+
+// A CollisionStrategy is what to do when two instrument names, or two
+// attribute keys of one instrument, translate to the same Prometheus
+// name. Whichever is seen first keeps the name. Every collision is
+// reported to [Options.OnError] as a
+// [*NameCollisionError], whatever the strategy.
+type CollisionStrategy int
+
+const (
+	// CollisionError drops the later instrument. For attribute keys,
+	// the whole instrument is dropped.
+	CollisionError CollisionStrategy = iota
+	// CollisionHashSuffix exports the later instrument or attribute
+	// under its translated name suffixed with a hash of the original
+	// name, such as "a_b_5f1ab3c2_total".
+	CollisionHashSuffix
+	// CollisionMerge exports the later instrument to the same metric
+	// as the first, provided they're the same type. For attribute
+	// keys, the label takes the value of the key seen first, or the
+	// first in sorted order if they were first seen together.
+	CollisionMerge
+)
+
+// A NameCollisionError describes two names which translate to the same
+// Prometheus name.
+type NameCollisionError struct {
+	// Label is true for attribute keys, and false for instruments.
+	Label bool
+	// Metric is the metric the collision is in, or would have been
+	// in.
+	Metric string
+	// Name is the translated name. For labels, First and Second
+	// are the attribute keys which translate to it, and for
+	// metrics, the names of the instruments whose streams do.
+	Name          string
+	First, Second string
+}
+
+func (e *NameCollisionError) Error() string {
+	if e.Label {
+		return fmt.Sprintf("smithyprom: attributes %q and %q of %s are both exported as label %q", e.First, e.Second, e.Metric, e.Name)
+	}
+	return fmt.Sprintf("smithyprom: instruments %q and %q are both exported as %q", e.First, e.Second, e.Name)
+}
+
+// hashSuffix returns a short, stable hash of name, for
+// disambiguating it from names it collides with.
+func hashSuffix(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+func logError(err error) {
+	log.Print(err)
+}
+
+// metricNames is the reverse map from Prometheus metric names to
+// the instruments exported under them, including the names of metrics
+// derived from an instrument, such as a histogram's min and max.
+type metricNames struct {
+	mu     sync.Mutex
+	owners map[string]*promInstrument
+}
+
+// claim records that i is exported as i.name, and its derived
+// metrics as theirs. If another instrument already has one of the
+// names, it returns that instrument, the name, and false. Instruments
+// for the same stream don't collide.
+func (n *metricNames) claim(i *promInstrument) (*promInstrument, string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	names := i.metricNames()
+	for _, name := range names {
+		if owner, ok := n.owners[name]; ok {
+			return owner, name, owner.key == i.key
+		}
+	}
+	if n.owners == nil {
+		n.owners = make(map[string]*promInstrument)
+	}
+	for _, name := range names {
+		n.owners[name] = i
+	}
+	return i, i.name, true
+}
+
+// instruments returns every instrument with a name.
+func (n *metricNames) instruments() []*promInstrument {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var is []*promInstrument
+	for name, i := range n.owners {
+		if name == i.name {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+// units returns the unit of each metric with one, by metric name.
+func (n *metricNames) units() map[string]string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	units := make(map[string]string)
+	for name, i := range n.owners {
+		// derived metrics' names don't end with the unit
+		if name == i.name && i.unit != "" {
+			units[name] = i.unit
+		}
+	}
+	return units
+}
@@ -0,0 +1,279 @@
+package smithyprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricNameCollisions(t *testing.T) {
+	tests := []struct {
+		testName   string
+		collisions CollisionStrategy
+		want       string
+	}{
+		{
+			testName:   "error",
+			collisions: CollisionError,
+			want: `
+# HELP a_b_total first
+# TYPE a_b_total counter
+a_b_total 1
+`,
+		},
+		{
+			testName:   "hash suffix",
+			collisions: CollisionHashSuffix,
+			want: `
+# HELP a_b_total first
+# TYPE a_b_total counter
+a_b_total 1
+# HELP a_b_` + hashSuffix("a-b") + `_total second
+# TYPE a_b_` + hashSuffix("a-b") + `_total counter
+a_b_` + hashSuffix("a-b") + `_total 2
+`,
+		},
+		{
+			testName:   "merge",
+			collisions: CollisionMerge,
+			want: `
+# HELP a_b_total first
+# TYPE a_b_total counter
+a_b_total 3
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var errs []error
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:   reg,
+				Collisions: tt.collisions,
+				OnError:    func(err error) { errs = append(errs, err) },
+			})
+			m := mp.Meter("test")
+
+			ctx := context.Background()
+			c1, err := m.Int64Counter("a.b", withDescription("first"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c1.Add(ctx, 1)
+			c2, err := m.Int64Counter("a-b", withDescription("second"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c2.Add(ctx, 2)
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+
+			var collision *NameCollisionError
+			if len(errs) != 1 || !errors.As(errs[0], &collision) {
+				t.Fatalf("got errors %v, want one collision", errs)
+			}
+			if collision.Label || collision.Name != "a_b_total" || collision.First != "a.b" || collision.Second != "a-b" {
+				t.Errorf("got collision %+v", collision)
+			}
+		})
+	}
+}
+
+// a view renaming an instrument to another's name collides with it,
+// rather than sharing its stream
+func TestMetricNameCollisionRenamed(t *testing.T) {
+	var errs []error
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry: reg,
+		Views: []View{func(i Instrument) (Stream, bool) {
+			if i.Name == "a" {
+				return Stream{Name: "b"}, true
+			}
+			return Stream{}, true
+		}},
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	m := mp.Meter("test")
+
+	ctx := context.Background()
+	b, _ := m.Int64Counter("b", withDescription("real"))
+	b.Add(ctx, 1)
+	a, _ := m.Int64Counter("a", withDescription("renamed"))
+	a.Add(ctx, 2)
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP b_total real
+# TYPE b_total counter
+b_total 1
+`)); err != nil {
+		t.Error(err)
+	}
+
+	var collision *NameCollisionError
+	if len(errs) != 1 || !errors.As(errs[0], &collision) {
+		t.Fatalf("got errors %v, want one collision", errs)
+	}
+	if collision.Name != "b_total" || collision.First != "b" || collision.Second != "a" {
+		t.Errorf("got collision %+v", collision)
+	}
+}
+
+// summaries with different windows or objectives collide, even when
+// they're from the same instrument
+func TestMetricNameCollisionSummaries(t *testing.T) {
+	tests := []struct {
+		testName string
+		second   AggregationSummary
+	}{
+		{
+			testName: "objectives",
+			second:   AggregationSummary{Objectives: map[float64]float64{0.5: 0.05}},
+		},
+		{
+			testName: "max age",
+			second:   AggregationSummary{MaxAge: time.Minute},
+		},
+		{
+			testName: "age buckets",
+			second:   AggregationSummary{AgeBuckets: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var errs []error
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:   reg,
+				Collisions: CollisionMerge,
+				Views: []View{func(i Instrument) (Stream, bool) {
+					if i.Scope == "second" {
+						return Stream{Aggregation: tt.second}, true
+					}
+					return Stream{Aggregation: AggregationSummary{}}, true
+				}},
+				OnError: func(err error) { errs = append(errs, err) },
+			})
+
+			ctx := context.Background()
+			h1, _ := mp.Meter("first").Float64Histogram("duration")
+			h1.Record(ctx, 1)
+			h2, _ := mp.Meter("second").Float64Histogram("duration")
+			h2.Record(ctx, 2)
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP duration 
+# TYPE duration summary
+duration{quantile="0.5"} 1
+duration{quantile="0.9"} 1
+duration{quantile="0.99"} 1
+duration_sum 1
+duration_count 1
+`)); err != nil {
+				t.Error(err)
+			}
+
+			var collision *NameCollisionError
+			if len(errs) != 1 || !errors.As(errs[0], &collision) {
+				t.Fatalf("got errors %v, want one collision", errs)
+			}
+		})
+	}
+}
+
+// merging needs the same type
+func TestMetricNameCollisionMergeTypes(t *testing.T) {
+	var errs []error
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:   reg,
+		Collisions: CollisionMerge,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	m := mp.Meter("test")
+
+	ctx := context.Background()
+	c, _ := m.Int64UpDownCounter("a.b")
+	c.Add(ctx, 1)
+	h, _ := m.Float64Histogram("a_b", withUnit("{thing}"))
+	h.Record(ctx, 1)
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP a_b 
+# TYPE a_b gauge
+a_b 1
+`)); err != nil {
+		t.Error(err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got errors %v, want one collision", errs)
+	}
+}
+
+func TestLabelNameCollisions(t *testing.T) {
+	tests := []struct {
+		testName   string
+		collisions CollisionStrategy
+		want       string
+	}{
+		{
+			testName:   "error",
+			collisions: CollisionError,
+			want:       ``,
+		},
+		{
+			testName:   "hash suffix",
+			collisions: CollisionHashSuffix,
+			want: `
+# HELP calls_total 
+# TYPE calls_total counter
+calls_total{op_x="get",op_x_` + hashSuffix("op_x") + `="put",svc="s3"} 1
+`,
+		},
+		{
+			testName:   "merge",
+			collisions: CollisionMerge,
+			want: `
+# HELP calls_total 
+# TYPE calls_total counter
+calls_total{op_x="get",svc="s3"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var errs []error
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:   reg,
+				Collisions: tt.collisions,
+				OnError:    func(err error) { errs = append(errs, err) },
+			})
+
+			c, err := mp.Meter("test").Int64Counter("calls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Add(context.Background(), 1, withAttrs("op.x", "get", "op_x", "put", "svc", "s3"))
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+
+			var collision *NameCollisionError
+			if len(errs) != 1 || !errors.As(errs[0], &collision) {
+				t.Fatalf("got errors %v, want one collision", errs)
+			}
+			if !collision.Label || collision.Name != "op_x" || collision.First != "op.x" || collision.Second != "op_x" {
+				t.Errorf("got collision %+v", collision)
+			}
+		})
+	}
+}
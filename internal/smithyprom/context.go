@@ -0,0 +1,99 @@
+package smithyprom
+
+import (
+	"context"
+)
+
+// A ContextLabel adds an attribute to every observation from a value
+// carried in its context, such as the tenant or caller a request is
+// made for. The AWS SDK passes the context of the API call through to
+// the instruments.
+//
+// Context values are unbounded, so only the values in an allowlist are
+// exported as they are, and everything else as Other, unless the label
+// is Unbounded because Extract bounds them itself.
+type ContextLabel struct {
+	// Key is the attribute key, such as "tenant". It's translated to
+	// a label name like any other attribute, and is subject to
+	// views' attribute filters. If the AWS SDK records an attribute
+	// with the same key, the SDK's value is kept.
+	Key string
+	// Extract returns the value in ctx, or "" if there is none.
+	Extract func(ctx context.Context) string
+	// Filter, if set, is called with each instrument name. Only
+	// instruments for which it returns true get the label.
+	Filter func(name string) bool
+	// Values is the allowlist of values. If nil, every value is
+	// exported as Other.
+	Values []string
+	// Unbounded exports every value as it is, ignoring Values, for
+	// when Extract is responsible for bounding them.
+	Unbounded bool
+	// Other replaces values not in Values. If empty, it's "other".
+	// An observation without a value has an empty label.
+	Other string
+}
+
+// contextLabel is a [ContextLabel] with its key and values boxed up
+// front, so that setting them as attributes doesn't allocate. values
+// is nil if the label is unbounded.
+type contextLabel struct {
+	key     any
+	extract func(ctx context.Context) string
+	filter  func(name string) bool
+	values  map[string]any
+	other   any
+	none    any
+}
+
+func newContextLabels(ls []ContextLabel) []contextLabel {
+	var cls []contextLabel
+	for _, l := range ls {
+		other := l.Other
+		if other == "" {
+			other = "other"
+		}
+		cl := contextLabel{
+			key:     l.Key,
+			extract: l.Extract,
+			filter:  l.Filter,
+			other:   other,
+			none:    "",
+		}
+		if !l.Unbounded {
+			cl.values = make(map[string]any, len(l.Values))
+			for _, v := range l.Values {
+				cl.values[v] = v
+			}
+		}
+		cls = append(cls, cl)
+	}
+	return cls
+}
+
+// value returns the boxed attribute value for ctx.
+func (l *contextLabel) value(ctx context.Context) any {
+	v := l.extract(ctx)
+	if v == "" {
+		return l.none
+	}
+	if l.values == nil {
+		return v
+	}
+	if b, ok := l.values[v]; ok {
+		return b
+	}
+	return l.other
+}
+
+// contextLabelsFor returns the labels which apply to the instrument
+// named name.
+func contextLabelsFor(ls []contextLabel, name string) []contextLabel {
+	var cls []contextLabel
+	for _, l := range ls {
+		if l.filter == nil || l.filter(name) {
+			cls = append(cls, l)
+		}
+	}
+	return cls
+}
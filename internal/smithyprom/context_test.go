@@ -0,0 +1,155 @@
+package smithyprom
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type tenantKey struct{}
+
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func tenant(ctx context.Context) string {
+	s, _ := ctx.Value(tenantKey{}).(string)
+	return s
+}
+
+func TestContextLabels(t *testing.T) {
+	tests := []struct {
+		testName string
+		label    ContextLabel
+		views    []View
+		record   func(metrics.Int64Counter)
+		want     string
+	}{
+		{
+			testName: "allowlist",
+			label:    ContextLabel{Key: "tenant", Extract: tenant, Values: []string{"a", "b"}},
+			record: func(c metrics.Int64Counter) {
+				ctx := context.Background()
+				c.Add(withTenant(ctx, "a"), 1, withAttrs("rpc.method", "List"))
+				c.Add(withTenant(ctx, "a"), 1, withAttrs("rpc.method", "List"))
+				c.Add(withTenant(ctx, "b"), 1, withAttrs("rpc.method", "List"))
+				c.Add(withTenant(ctx, "c"), 1, withAttrs("rpc.method", "List"))
+				c.Add(withTenant(ctx, "d"), 1, withAttrs("rpc.method", "List"))
+				c.Add(ctx, 1, withAttrs("rpc.method", "List"))
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="List",tenant=""} 1
+client_call_attempts_total{rpc_method="List",tenant="a"} 2
+client_call_attempts_total{rpc_method="List",tenant="b"} 1
+client_call_attempts_total{rpc_method="List",tenant="other"} 2
+`,
+		},
+		{
+			testName: "no allowlist",
+			label:    ContextLabel{Key: "tenant", Extract: tenant},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "c"), 1)
+				c.Add(context.Background(), 1)
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{tenant=""} 1
+client_call_attempts_total{tenant="other"} 1
+`,
+		},
+		{
+			testName: "unbounded",
+			label:    ContextLabel{Key: "tenant", Extract: tenant, Values: []string{"a"}, Unbounded: true},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "c"), 1)
+				c.Add(context.Background(), 1)
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{tenant=""} 1
+client_call_attempts_total{tenant="c"} 1
+`,
+		},
+		{
+			testName: "custom other",
+			label:    ContextLabel{Key: "tenant", Extract: tenant, Values: []string{"a"}, Other: "unlisted"},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "b"), 1)
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{tenant="unlisted"} 1
+`,
+		},
+		{
+			testName: "sdk attribute wins",
+			label:    ContextLabel{Key: "rpc.method", Extract: tenant, Values: []string{"a"}},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "a"), 1, withAttrs("rpc.method", "List"))
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="List"} 1
+`,
+		},
+		{
+			testName: "filtered by instrument",
+			label: ContextLabel{Key: "tenant", Extract: tenant, Values: []string{"a"}, Filter: func(name string) bool {
+				return name != "client.call.attempts"
+			}},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "a"), 1, withAttrs("rpc.method", "List"))
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="List"} 1
+`,
+		},
+		{
+			testName: "filtered by view",
+			label:    ContextLabel{Key: "tenant", Extract: tenant, Values: []string{"a"}},
+			views: []View{func(i Instrument) (Stream, bool) {
+				return Stream{AttributeFilter: func(key string) bool { return key != "tenant" }}, true
+			}},
+			record: func(c metrics.Int64Counter) {
+				c.Add(withTenant(context.Background(), "a"), 1, withAttrs("rpc.method", "List"))
+			},
+			want: `
+# HELP client_call_attempts_total
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="List"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:      reg,
+				Views:         tt.views,
+				ContextLabels: []ContextLabel{tt.label},
+				OnError:       func(err error) { t.Error(err) },
+			})
+			c, err := mp.Meter("test").Int64Counter("client.call.attempts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.record(c)
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package smithyprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterMetric exports a monotonic sum.
+type counterMetric struct {
+	*prometheus.CounterVec
+}
+
+func newCounterMetric(i *promInstrument, labelNames []string) *counterMetric {
+	return &counterMetric{
+		prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: i.name,
+				Help: i.description,
+			},
+			labelNames,
+		),
+	}
+}
+
+func (m *counterMetric) child(labelValues []string) series {
+	return counterSeries{m.WithLabelValues(labelValues...)}
+}
+
+func (m *counterMetric) deleteChild(labelValues []string) {
+	m.DeleteLabelValues(labelValues...)
+}
+
+type counterSeries struct {
+	prometheus.Counter
+}
+
+func (s counterSeries) record(v float64) {
+	if v < 0 {
+		// counters panic on this. A view could have asked for
+		// the sum of a histogram with a negative observation.
+		return
+	}
+	s.Add(v)
+}
@@ -0,0 +1,333 @@
+package smithyprom
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"demo/internal/otelexport"
+)
+
+// The native adapter and the OTEL adapter are meant to be two routes
+// to the same output. These tests drive both with the same instrument
+// calls and compare what ends up in the registry.
+
+func withAttrs(kv ...string) metrics.RecordMetricOption {
+	return func(o *metrics.RecordMetricOptions) {
+		for i := 0; i < len(kv); i += 2 {
+			o.Properties.Set(kv[i], kv[i+1])
+		}
+	}
+}
+
+// equivalenceScenarios are synthetic versions of what the AWS SDK does
+// with a meter-provider.
+var equivalenceScenarios = []struct {
+	testName string
+	run      func(t *testing.T, mp metrics.MeterProvider)
+}{
+	{
+		testName: "labeled counter",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			c, err := mp.Meter("test").Int64Counter("client.call.attempts",
+				withUnit("{attempt}"), withDescription("The number of attempts for an individual operation"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			c.Add(ctx, 1, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+			c.Add(ctx, 2, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+			c.Add(ctx, 1, withAttrs("rpc.service", "DynamoDB", "rpc.method", "ListTables"))
+		},
+	},
+	{
+		testName: "unlabeled counter",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			c, err := mp.Meter("test").Int64Counter("client.retries", withDescription("retries"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Add(context.Background(), 3)
+		},
+	},
+	{
+		testName: "float counter",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			c, err := mp.Meter("test").Float64Counter("dynamodb.consumed_capacity.read",
+				withUnit("{capacity_unit}"), withDescription("Read capacity units consumed"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			c.Add(ctx, 0.5, withAttrs("aws.dynamodb.table", "demo-table"))
+			c.Add(ctx, 1.5, withAttrs("aws.dynamodb.table", "demo-table"))
+		},
+	},
+	{
+		testName: "up-down counter",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			c, err := mp.Meter("test").Int64UpDownCounter("client.http.connections.usage",
+				withUnit("{connection}"), withDescription("Current state of connections pool"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			c.Add(ctx, 1, withAttrs("state", "acquired"))
+			c.Add(ctx, 1, withAttrs("state", "acquired"))
+			c.Add(ctx, -1, withAttrs("state", "acquired"))
+			c.Add(ctx, 1, withAttrs("state", "idle"))
+		},
+	},
+	{
+		testName: "labeled histogram",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			h, err := mp.Meter("test").Float64Histogram("client.call.duration",
+				withUnit("s"), withDescription("Overall call duration"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			for _, v := range []float64{0.001, 0.02, 0.3, 4, 20} {
+				h.Record(ctx, v, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+			}
+			h.Record(ctx, 0.05, withAttrs("rpc.service", "DynamoDB", "rpc.method", "ListTables"))
+		},
+	},
+	{
+		testName: "unlabeled histogram",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			h, err := mp.Meter("test").Float64Histogram("client.http.connections.acquire_duration",
+				withUnit("s"), withDescription("The time it takes a request to acquire a connection"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Record(context.Background(), 0.002)
+			h.Record(context.Background(), 0.7)
+		},
+	},
+	{
+		testName: "byte histogram",
+		run: func(t *testing.T, mp metrics.MeterProvider) {
+			h, err := mp.Meter("test").Float64Histogram("http.client.response.body.size",
+				withUnit("By"), withDescription("Size of HTTP client response bodies"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []float64{0, 100, 5000, 3e6} {
+				h.Record(context.Background(), v, withAttrs("rpc.service", "S3", "rpc.method", "GetObject"))
+			}
+		},
+	},
+}
+
+func TestEquivalence(t *testing.T) {
+	for _, tt := range equivalenceScenarios {
+		t.Run(tt.testName, func(t *testing.T) {
+			promRegistry := prometheus.NewRegistry()
+			tt.run(t, NewMeterProvider(&Options{
+				Registry:  promRegistry,
+				Namespace: "aws",
+			}))
+
+			otelRegistry := prometheus.NewRegistry()
+			otelProvider, err := otelexport.NewMeterProvider(otelRegistry, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.run(t, smithyotelmetrics.Adapt(otelProvider))
+
+			for _, d := range diffRegistries(t, promRegistry, otelRegistry) {
+				t.Errorf("native vs otel: %s", d)
+			}
+		})
+	}
+}
+
+// make sure the harness would notice if the adapters disagreed
+func TestDiffMetricFamilies(t *testing.T) {
+	a := prometheus.NewRegistry()
+	b := prometheus.NewRegistry()
+
+	ac := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "calls_total", Help: "calls"}, []string{"op"})
+	ac.WithLabelValues("get").Add(2)
+	ac.WithLabelValues("put").Add(1)
+	a.MustRegister(ac)
+	a.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight", Help: "in flight"}))
+
+	bc := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "calls_total", Help: "calls"}, []string{"op"})
+	bc.WithLabelValues("get").Add(3)
+	bc.WithLabelValues("list").Add(1)
+	b.MustRegister(bc)
+	bh := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency", Help: "latency"})
+	bh.Observe(1)
+	b.MustRegister(bh)
+
+	got := diffRegistries(t, a, b)
+	want := []string{
+		`calls_total{op="get"}: value 2 != 3`,
+		`calls_total{op="list"}: only in second`,
+		`calls_total{op="put"}: only in first`,
+		`in_flight: only in first`,
+		`latency: only in second`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diffRegistries() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func withUnit(u string) metrics.InstrumentOption {
+	return func(o *metrics.InstrumentOptions) {
+		o.UnitLabel = u
+	}
+}
+
+func withDescription(d string) metrics.InstrumentOption {
+	return func(o *metrics.InstrumentOptions) {
+		o.Description = d
+	}
+}
+
+// diffRegistries gathers both registries and describes every
+// difference between them.
+func diffRegistries(t *testing.T, a, b prometheus.Gatherer) []string {
+	t.Helper()
+
+	aFamilies, err := a.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bFamilies, err := b.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return diffMetricFamilies(aFamilies, bFamilies)
+}
+
+// diffMetricFamilies compares metric families by name, type, help,
+// label-sets, values and histogram buckets.
+func diffMetricFamilies(a, b []*dto.MetricFamily) []string {
+	var diffs []string
+
+	aByName := familiesByName(a)
+	bByName := familiesByName(b)
+
+	for _, name := range sortedUnion(aByName, bByName) {
+		af, bf := aByName[name], bByName[name]
+		switch {
+		case bf == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: only in first", name))
+			continue
+		case af == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: only in second", name))
+			continue
+		}
+
+		if af.GetType() != bf.GetType() {
+			diffs = append(diffs, fmt.Sprintf("%s: type %s != %s", name, af.GetType(), bf.GetType()))
+			continue
+		}
+		if af.GetHelp() != bf.GetHelp() {
+			diffs = append(diffs, fmt.Sprintf("%s: help %q != %q", name, af.GetHelp(), bf.GetHelp()))
+		}
+
+		aMetrics := metricsByLabels(af)
+		bMetrics := metricsByLabels(bf)
+		for _, lbls := range sortedUnion(aMetrics, bMetrics) {
+			am, bm := aMetrics[lbls], bMetrics[lbls]
+			switch {
+			case bm == nil:
+				diffs = append(diffs, fmt.Sprintf("%s%s: only in first", name, lbls))
+			case am == nil:
+				diffs = append(diffs, fmt.Sprintf("%s%s: only in second", name, lbls))
+			default:
+				for _, d := range diffMetric(af.GetType(), am, bm) {
+					diffs = append(diffs, fmt.Sprintf("%s%s: %s", name, lbls, d))
+				}
+			}
+		}
+	}
+
+	return diffs
+}
+
+func diffMetric(typ dto.MetricType, a, b *dto.Metric) []string {
+	var diffs []string
+	switch typ {
+	case dto.MetricType_COUNTER:
+		if a.GetCounter().GetValue() != b.GetCounter().GetValue() {
+			diffs = append(diffs, fmt.Sprintf("value %v != %v", a.GetCounter().GetValue(), b.GetCounter().GetValue()))
+		}
+	case dto.MetricType_GAUGE:
+		if a.GetGauge().GetValue() != b.GetGauge().GetValue() {
+			diffs = append(diffs, fmt.Sprintf("value %v != %v", a.GetGauge().GetValue(), b.GetGauge().GetValue()))
+		}
+	case dto.MetricType_HISTOGRAM:
+		ah, bh := a.GetHistogram(), b.GetHistogram()
+		if ah.GetSampleCount() != bh.GetSampleCount() {
+			diffs = append(diffs, fmt.Sprintf("count %v != %v", ah.GetSampleCount(), bh.GetSampleCount()))
+		}
+		if ah.GetSampleSum() != bh.GetSampleSum() {
+			diffs = append(diffs, fmt.Sprintf("sum %v != %v", ah.GetSampleSum(), bh.GetSampleSum()))
+		}
+		if !slices.EqualFunc(ah.GetBucket(), bh.GetBucket(), func(x, y *dto.Bucket) bool {
+			return x.GetUpperBound() == y.GetUpperBound() && x.GetCumulativeCount() == y.GetCumulativeCount()
+		}) {
+			diffs = append(diffs, fmt.Sprintf("buckets %v != %v", formatBuckets(ah.GetBucket()), formatBuckets(bh.GetBucket())))
+		}
+	default:
+		diffs = append(diffs, fmt.Sprintf("unsupported metric type %s", typ))
+	}
+	return diffs
+}
+
+func familiesByName(mfs []*dto.MetricFamily) map[string]*dto.MetricFamily {
+	m := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		m[mf.GetName()] = mf
+	}
+	return m
+}
+
+// metricsByLabels keys each metric in mf by its formatted label-set.
+func metricsByLabels(mf *dto.MetricFamily) map[string]*dto.Metric {
+	m := make(map[string]*dto.Metric, len(mf.GetMetric()))
+	for _, metric := range mf.GetMetric() {
+		var lbls []string
+		for _, lp := range metric.GetLabel() {
+			lbls = append(lbls, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+		}
+		slices.Sort(lbls)
+		m["{"+strings.Join(lbls, ",")+"}"] = metric
+	}
+	return m
+}
+
+func formatBuckets(buckets []*dto.Bucket) string {
+	var parts []string
+	for _, b := range buckets {
+		parts = append(parts, fmt.Sprintf("%v:%d", b.GetUpperBound(), b.GetCumulativeCount()))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func sortedUnion[V any](a, b map[string]V) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
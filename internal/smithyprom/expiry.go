@@ -0,0 +1,71 @@
+package smithyprom
+
+import "time"
+
+// A Clock is the source of time for expiring series, so that tests
+// can control it.
+type Clock interface {
+	Now() time.Time
+	// NewTicker is like [time.NewTicker], returning the ticker's
+	// channel and a function which stops it.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// sweepEvery sweeps for idle series every d, until the provider is
+// closed.
+func (p *MeterProvider) sweepEvery(d time.Duration) {
+	ticks, stop := p.clock.NewTicker(d)
+	defer stop()
+	for {
+		select {
+		case <-ticks:
+			p.sweep()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// sweep deletes every series which hasn't been recorded to within
+// the TTL.
+func (p *MeterProvider) sweep() {
+	cutoff := p.clock.Now().Add(-p.seriesTTL).UnixNano()
+	for _, i := range p.names.instruments() {
+		state := i.state.Load()
+		if state == nil {
+			continue
+		}
+		for _, schema := range state.schemas {
+			if schema.metric != nil {
+				schema.expire(cutoff)
+			}
+		}
+	}
+}
+
+// expire deletes series last recorded to before cutoff, in Unix
+// nanoseconds.
+//
+// An observation racing with the expiry of its series may be lost,
+// but only if the series was idle for the whole TTL beforehand.
+func (s *labelSchema) expire(cutoff int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, c := range s.children {
+		if c.lastUpdate.Load() < cutoff {
+			delete(s.children, k)
+			s.metric.deleteChild(c.labelValues)
+		}
+	}
+}
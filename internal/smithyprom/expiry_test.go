@@ -0,0 +1,110 @@
+package smithyprom
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeClock is a Clock whose time and ticks are set by the test.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Unix(1_700_000_000, 0),
+		ticks: make(chan time.Time),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) (<-chan time.Time, func()) {
+	return c.ticks, func() {}
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// tick delivers a tick and waits for the sweep it triggers. The
+// ticker channel is unbuffered, so the second send can't complete
+// until the sweeper is back waiting.
+func (c *fakeClock) tick() {
+	c.ticks <- time.Time{}
+	c.ticks <- time.Time{}
+}
+
+func TestSeriesTTL(t *testing.T) {
+	clock := newFakeClock()
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:  reg,
+		SeriesTTL: time.Minute,
+		Clock:     clock,
+	})
+	defer mp.Close()
+
+	c, err := mp.Meter("test").Int64Counter("client.call.errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c.Add(ctx, 1, withAttrs("error.type", "ThrottlingException"))
+	c.Add(ctx, 1, withAttrs("error.type", "InternalError"))
+
+	// one error keeps happening, the other was a one-off
+	clock.advance(40 * time.Second)
+	c.Add(ctx, 1, withAttrs("error.type", "ThrottlingException"))
+	clock.tick()
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_errors_total 
+# TYPE client_call_errors_total counter
+client_call_errors_total{error_type="InternalError"} 1
+client_call_errors_total{error_type="ThrottlingException"} 2
+`))
+	if err != nil {
+		t.Error(err)
+	}
+
+	clock.advance(40 * time.Second)
+	clock.tick()
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_errors_total 
+# TYPE client_call_errors_total counter
+client_call_errors_total{error_type="ThrottlingException"} 2
+`))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// an expired series starts over
+	c.Add(ctx, 1, withAttrs("error.type", "InternalError"))
+	clock.advance(40 * time.Second)
+	clock.tick()
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_errors_total 
+# TYPE client_call_errors_total counter
+client_call_errors_total{error_type="InternalError"} 1
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,46 @@
+package smithyprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gaugeMetric exports either a non-monotonic sum (from an up-down
+// counter) or the last value recorded.
+type gaugeMetric struct {
+	*prometheus.GaugeVec
+	lastValue bool
+}
+
+func newGaugeMetric(i *promInstrument, labelNames []string) *gaugeMetric {
+	return &gaugeMetric{
+		GaugeVec: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: i.name,
+				Help: i.description,
+			},
+			labelNames,
+		),
+		lastValue: i.agg == aggregationGaugeSet,
+	}
+}
+
+func (m *gaugeMetric) child(labelValues []string) series {
+	return gaugeSeries{m.WithLabelValues(labelValues...), m.lastValue}
+}
+
+func (m *gaugeMetric) deleteChild(labelValues []string) {
+	m.DeleteLabelValues(labelValues...)
+}
+
+type gaugeSeries struct {
+	prometheus.Gauge
+	lastValue bool
+}
+
+func (s gaugeSeries) record(v float64) {
+	if s.lastValue {
+		s.Set(v)
+		return
+	}
+	s.Add(v)
+}
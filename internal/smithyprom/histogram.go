@@ -0,0 +1,64 @@
+package smithyprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// histogramMetric exports an explicit-bucket histogram, and
+// optionally min and max gauges.
+type histogramMetric struct {
+	*prometheus.HistogramVec
+	minMax *minMaxMetric
+}
+
+func newHistogramMetric(i *promInstrument, labelNames []string) *histogramMetric {
+	var minMax *minMaxMetric
+	if i.minMax != nil {
+		minMax = newMinMaxMetric(i, labelNames)
+	}
+	return &histogramMetric{
+		HistogramVec: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    i.name,
+				Help:    i.description,
+				Buckets: i.buckets,
+			},
+			labelNames,
+		),
+		minMax: minMax,
+	}
+}
+
+func (m *histogramMetric) Collect(ch chan<- prometheus.Metric) {
+	m.HistogramVec.Collect(ch)
+	if m.minMax != nil {
+		m.minMax.collect(ch)
+	}
+}
+
+func (m *histogramMetric) child(labelValues []string) series {
+	s := histogramSeries{Observer: m.WithLabelValues(labelValues...)}
+	if m.minMax != nil {
+		s.minMax = m.minMax.child(labelValues)
+	}
+	return s
+}
+
+func (m *histogramMetric) deleteChild(labelValues []string) {
+	m.DeleteLabelValues(labelValues...)
+	if m.minMax != nil {
+		m.minMax.deleteChild(labelValues)
+	}
+}
+
+type histogramSeries struct {
+	prometheus.Observer
+	minMax *minMaxTracker
+}
+
+func (s histogramSeries) record(v float64) {
+	s.Observe(v)
+	if s.minMax != nil {
+		s.minMax.observe(v)
+	}
+}
@@ -0,0 +1,655 @@
+package smithyprom
+
+import (
+	"context"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A promInstrument wraps a Prometheus metric and presents it
+// as a [metrics.Instrument]. We defer construction of the metric
+// until it is used, because we don't know if we have labels until then.
+// The metric isn't registered: the provider's collector collects it.
+//
+// Each promInstrument is one stream, in the sense of views. An
+// instrument handed to the AWS SDK may be backed by more than one.
+type promInstrument struct {
+	key         cacheKey
+	name        string
+	description string
+	naming      NamingStrategy
+	agg         aggregation
+	buckets     []float64
+	summary     AggregationSummary
+	// if set, restricts which attributes become labels
+	attributeFilter func(key string) bool
+	collisions      CollisionStrategy
+	onError         func(error)
+	clock           Clock
+	expires         bool
+	// if set, histograms also export min and max
+	minMax *MinMaxOptions
+	// unit is the OpenMetrics unit, if any
+	unit string
+
+	// state is nil until the first observation
+	initMu sync.Mutex
+	state  atomic.Pointer[instrumentState]
+}
+
+// instrumentState is what a promInstrument has learned from its
+// observations. It's replaced, rather than modified, when an
+// observation has an attribute key which hasn't been seen before.
+type instrumentState struct {
+	// known is every attribute key seen, whether or not it's a
+	// label
+	known map[string]struct{}
+	// labelKeys are the attribute keys which are labels of any
+	// schema. They're boxed once so that looking them up doesn't
+	// allocate.
+	labelKeys []any
+	// schemas are in the order they were made. Each has the labels
+	// of the one before, and more.
+	schemas []schemaRef
+}
+
+// schemaRef is a labelSchema, along with where its labels are in
+// labelKeys.
+type schemaRef struct {
+	*labelSchema
+	// labels are the indexes of the schema's labels in labelKeys,
+	// in label order, and missing those of the labels it lacks
+	labels  []int
+	missing []int
+}
+
+// labelSchema is the Prometheus metric an instrument's series with a
+// particular set of labels are exported by. The collector is
+// unchecked, so one metric name can have several.
+type labelSchema struct {
+	// metric is nil if the schema couldn't be exported
+	metric promMetric
+	// keys are the attribute keys which become labels, in label
+	// order
+	keys []string
+
+	// children memoizes the series for each combination of label
+	// values
+	mu       sync.RWMutex
+	children map[string]*child
+}
+
+// child is a series, along with what's needed to expire it.
+type child struct {
+	series
+	labelValues []string
+	// lastUpdate is when the series was last recorded to, in Unix
+	// nanoseconds. It's only kept if series expire.
+	lastUpdate atomic.Int64
+}
+
+// promMetric is the Prometheus half of a promInstrument. There is
+// one implementation per aggregation.
+type promMetric interface {
+	prometheus.Collector
+	// child returns the series for labelValues.
+	child(labelValues []string) series
+	// deleteChild deletes the series for labelValues.
+	deleteChild(labelValues []string)
+}
+
+// series is a single child of a promMetric.
+type series interface {
+	record(v float64)
+}
+
+// aggregation is what kind of Prometheus metric we export a
+// stream as. It's the resolved form of an [Aggregation].
+type aggregation int
+
+const (
+	aggregationCounter aggregation = iota
+	aggregationGaugeAdd
+	aggregationGaugeSet
+	aggregationHistogram
+	aggregationSummary
+)
+
+// resolveAggregation works out how to export a stream for an
+// instrument of kind k, recording in unit.
+func resolveAggregation(a Aggregation, k InstrumentKind, unit string) (aggregation, []float64) {
+	switch a := a.(type) {
+	case AggregationSum:
+		if k == InstrumentKindUpDownCounter {
+			return aggregationGaugeAdd, nil
+		}
+		return aggregationCounter, nil
+	case AggregationLastValue:
+		return aggregationGaugeSet, nil
+	case AggregationExplicitBucketHistogram:
+		if a.Boundaries == nil {
+			return aggregationHistogram, defaultBuckets(unit)
+		}
+		return aggregationHistogram, a.Boundaries
+	case AggregationSummary:
+		return aggregationSummary, nil
+	}
+
+	// default
+	switch k {
+	case InstrumentKindUpDownCounter:
+		return aggregationGaugeAdd, nil
+	case InstrumentKindHistogram:
+		return aggregationHistogram, defaultBuckets(unit)
+	}
+	return aggregationCounter, nil
+}
+
+// DefByteBuckets are the default buckets for histograms of bytes
+// (UCUM unit "By"), from 64B to 256MiB in multiples of four.
+var DefByteBuckets = prometheus.ExponentialBuckets(64, 4, 12)
+
+// defaultBuckets returns the default buckets for a histogram
+// recording in unit. [prometheus.DefBuckets] are meant for seconds.
+func defaultBuckets(unit string) []float64 {
+	if unit == "By" {
+		return DefByteBuckets
+	}
+	return prometheus.DefBuckets
+}
+
+// instrumentType is the Prometheus type we export an
+// aggregation as, which determines naming.
+func (a aggregation) instrumentType() instrumentType {
+	switch a {
+	case aggregationGaugeAdd, aggregationGaugeSet:
+		return instrumentTypeGauge
+	case aggregationHistogram:
+		return instrumentTypeHistogram
+	case aggregationSummary:
+		return instrumentTypeSummary
+	}
+	return instrumentTypeCounter
+}
+
+// record records v with the attributes in props. st is scratch
+// space.
+func (i *promInstrument) record(props *smithy.Properties, st *recordState, v float64) {
+	state := i.getState(props)
+
+	// a missing attribute is recorded as an empty label
+	st.all = st.all[:0]
+	for _, k := range state.labelKeys {
+		lv, _ := props.Get(k).(string)
+		st.all = append(st.all, lv)
+	}
+	schema := state.schemaFor(st.all)
+	if schema.metric == nil {
+		return
+	}
+
+	st.vals = st.vals[:0]
+	st.key = st.key[:0]
+	for _, l := range schema.labels {
+		lv := st.all[l]
+		st.vals = append(st.vals, lv)
+		st.key = append(st.key, lv...)
+		// label values are UTF-8, so can't contain this
+		st.key = append(st.key, 0xff)
+	}
+	var now int64
+	if i.expires {
+		now = i.clock.Now().UnixNano()
+	}
+	c := schema.child(st.key, st.vals, now)
+	if i.expires {
+		c.lastUpdate.Store(now)
+	}
+	c.record(v)
+}
+
+// collect sends the instrument's metrics to ch, if it has any.
+func (i *promInstrument) collect(ch chan<- prometheus.Metric) {
+	state := i.state.Load()
+	if state == nil {
+		return
+	}
+	for _, schema := range state.schemas {
+		if schema.metric != nil {
+			schema.metric.Collect(ch)
+		}
+	}
+}
+
+// getState returns the instrument's state, adding a schema for the
+// attributes in props if any of their keys are new.
+func (i *promInstrument) getState(props *smithy.Properties) *instrumentState {
+	attrs := propertiesMap(props)
+	if state := i.state.Load(); state != nil && state.knows(attrs) {
+		return state
+	}
+
+	i.initMu.Lock()
+	defer i.initMu.Unlock()
+	state := i.state.Load()
+	if state != nil && state.knows(attrs) {
+		return state
+	}
+	state = i.newState(state, attrs)
+	i.state.Store(state)
+	return state
+}
+
+// knows returns whether every attribute key in attrs has been seen.
+func (s *instrumentState) knows(attrs map[any]any) bool {
+	for k, v := range attrs {
+		key, ok := k.(string)
+		if !ok || v == nil {
+			continue
+		}
+		if _, ok := s.known[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaFor returns the schema for an observation with the values of
+// labelKeys in vals. That's the first schema with a label for each
+// non-empty value, so that each series is only exported by one
+// schema: Prometheus doesn't tell an empty label from a missing one.
+func (s *instrumentState) schemaFor(vals []string) schemaRef {
+	for _, schema := range s.schemas {
+		ok := true
+		for _, m := range schema.missing {
+			if vals[m] != "" {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return schema
+		}
+	}
+	// the last schema has every label
+	return s.schemas[len(s.schemas)-1]
+}
+
+// newState returns the state after seeing the attributes in attrs,
+// which have keys old hasn't seen. old is nil for the first
+// observation.
+func (i *promInstrument) newState(old *instrumentState, attrs map[any]any) *instrumentState {
+	state := &instrumentState{known: make(map[string]struct{})}
+	var schemas []*labelSchema
+	var prev []string
+	if old != nil {
+		maps.Copy(state.known, old.known)
+		for _, schema := range old.schemas {
+			schemas = append(schemas, schema.labelSchema)
+		}
+		prev = schemas[len(schemas)-1].keys
+	}
+
+	var added []string
+	for k, v := range attrs {
+		key, ok := k.(string)
+		if !ok || v == nil {
+			continue
+		}
+		if _, ok := state.known[key]; ok {
+			continue
+		}
+		state.known[key] = struct{}{}
+		if i.attributeFilter == nil || i.attributeFilter(key) {
+			added = append(added, key)
+		}
+	}
+	if old != nil && len(added) == 0 {
+		// only keys which aren't labels
+		state.labelKeys = old.labelKeys
+		state.schemas = old.schemas
+		return state
+	}
+	slices.Sort(added)
+	schemas = append(schemas, i.newSchema(prev, added))
+
+	// the last schema has every label
+	last := schemas[len(schemas)-1]
+	index := make(map[string]int, len(last.keys))
+	for n, k := range last.keys {
+		index[k] = n
+		state.labelKeys = append(state.labelKeys, k)
+	}
+	for _, schema := range schemas {
+		ref := schemaRef{labelSchema: schema}
+		for _, k := range schema.keys {
+			ref.labels = append(ref.labels, index[k])
+		}
+		for n := range last.keys {
+			if !slices.Contains(ref.labels, n) {
+				ref.missing = append(ref.missing, n)
+			}
+		}
+		state.schemas = append(state.schemas, ref)
+	}
+	return state
+}
+
+// child returns the series for a combination of label values,
+// creating it if need be. key identifies the combination, and now
+// is when a new series is created.
+func (s *labelSchema) child(key []byte, labelValues []string, now int64) *child {
+	s.mu.RLock()
+	c, ok := s.children[string(key)]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.children[string(key)]; ok {
+		return c
+	}
+	c = &child{
+		series:      s.metric.child(labelValues),
+		labelValues: slices.Clone(labelValues),
+	}
+	c.lastUpdate.Store(now)
+	s.children[string(key)] = c
+	return c
+}
+
+// newSchema creates the Prometheus metric for a schema with the
+// labels of the schema before, prev, and a label for each of added.
+// Collisions are only reported for added keys, as the others' were
+// reported when they were added.
+func (i *promInstrument) newSchema(prev, added []string) *labelSchema {
+	keys := slices.Concat(prev, added)
+	labelNames := make([]string, 0, len(keys))
+	labelKeys := make([]string, 0, len(keys))
+	keysByName := make(map[string]string, len(keys))
+	for n, k := range keys {
+		name := i.naming.labelName(k)
+		if i.bucketLabel(name) {
+			// even with KeepReservedLabels, as the Prometheus
+			// client panics on them
+			name = "exported_" + name
+		}
+		if first, ok := keysByName[name]; ok {
+			if n >= len(prev) {
+				i.onError(&NameCollisionError{Label: true, Metric: i.name, Name: name, First: first, Second: k})
+			}
+			switch i.collisions {
+			case CollisionHashSuffix:
+				name = name + "_" + hashSuffix(k)
+				if _, ok := keysByName[name]; ok {
+					return &labelSchema{keys: keys}
+				}
+			case CollisionMerge:
+				// the key seen first wins, or the first in
+				// sorted order if they're seen together
+				continue
+			default:
+				return &labelSchema{keys: keys}
+			}
+		}
+		keysByName[name] = k
+		labelKeys = append(labelKeys, k)
+		labelNames = append(labelNames, name)
+	}
+
+	return &labelSchema{
+		metric:   i.newMetric(labelNames),
+		keys:     labelKeys,
+		children: make(map[string]*child),
+	}
+}
+
+// bucketLabel returns whether name is a label which the Prometheus
+// client reserves for the metric's buckets or quantiles.
+func (i *promInstrument) bucketLabel(name string) bool {
+	switch i.agg {
+	case aggregationHistogram, aggregationSummary:
+		return name == "le" || name == "quantile"
+	}
+	return false
+}
+
+// metricNames returns the names of the metrics i is exported as.
+func (i *promInstrument) metricNames() []string {
+	if i.agg == aggregationHistogram && i.minMax != nil {
+		return []string{i.name, i.name + "_min", i.name + "_max"}
+	}
+	return []string{i.name}
+}
+
+func (i *promInstrument) newMetric(labelNames []string) promMetric {
+	switch i.agg {
+	case aggregationGaugeAdd, aggregationGaugeSet:
+		return newGaugeMetric(i, labelNames)
+	case aggregationHistogram:
+		return newHistogramMetric(i, labelNames)
+	case aggregationSummary:
+		return newSummaryMetric(i, labelNames)
+	}
+	return newCounterMetric(i, labelNames)
+}
+
+func collectInstrumentOptions(opts []metrics.InstrumentOption) *metrics.InstrumentOptions {
+	o := &metrics.InstrumentOptions{}
+	for _, fn := range opts {
+		fn(o)
+	}
+	return o
+}
+
+// streamInstruments adapts the streams behind an instrument to
+// the smithy-go instrument interfaces.
+type streamInstruments[T float64 | int64] struct {
+	streams []*promInstrument
+	labels  []contextLabel
+	states  sync.Pool
+}
+
+func newStreamInstruments[T float64 | int64](streams []*promInstrument, labels []contextLabel) *streamInstruments[T] {
+	s := &streamInstruments[T]{streams: streams, labels: labels}
+	s.states.New = func() any { return &recordState{} }
+	return s
+}
+
+// recordState is scratch space for recording an observation. It's
+// pooled per instrument, so that once warm, recording doesn't
+// allocate.
+type recordState struct {
+	opts metrics.RecordMetricOptions
+	// all are the values of every label key, and vals those of
+	// the labels of the schema recorded to
+	all  []string
+	vals []string
+	key  []byte
+}
+
+// Add implements metrics.{Int|Float}64Counter and metrics.{Int|Float}64UpDownCounter.
+func (s *streamInstruments[T]) Add(ctx context.Context, v T, opts ...metrics.RecordMetricOption) {
+	s.record(ctx, v, opts)
+}
+
+// Record implements metrics.{Int|Float}64Histogram.
+func (s *streamInstruments[T]) Record(ctx context.Context, v T, opts ...metrics.RecordMetricOption) {
+	s.record(ctx, v, opts)
+}
+
+func (s *streamInstruments[T]) record(ctx context.Context, v T, opts []metrics.RecordMetricOption) {
+	// reusing the options reuses the map behind Properties
+	st := s.states.Get().(*recordState)
+	for _, f := range opts {
+		f(&st.opts)
+	}
+	for i := range s.labels {
+		l := &s.labels[i]
+		if st.opts.Properties.Get(l.key) == nil {
+			st.opts.Properties.Set(l.key, l.value(ctx))
+		}
+	}
+
+	for _, i := range s.streams {
+		i.record(&st.opts.Properties, st, float64(v))
+	}
+
+	clear(propertiesMap(&st.opts.Properties))
+	s.states.Put(st)
+}
+
+type instrumentType int
+
+const (
+	instrumentTypeCounter instrumentType = iota
+	instrumentTypeGauge
+	instrumentTypeHistogram
+	instrumentTypeSummary
+)
+
+// A NamingStrategy controls how instrument and attribute names are
+// mapped to Prometheus metric and label names. The zero value is the
+// legacy behavior: names are squashed to [a-zA-Z0-9_:] and unit and
+// counter suffixes are added.
+//
+// The options correspond to those of the OTEL Prometheus exporter.
+type NamingStrategy struct {
+	// UTF8 keeps names verbatim, such as "client.call.duration",
+	// which Prometheus 3 accepts. The Prometheus client library
+	// only allows this if model.NameValidationScheme is
+	// model.UTF8Validation, and the same setting switches the OTEL
+	// exporter to UTF-8 names.
+	UTF8 bool
+	// WithoutUnits omits the unit suffix, like otelprom.WithoutUnits.
+	WithoutUnits bool
+	// WithoutCounterSuffixes omits the _total suffix on counters,
+	// like otelprom.WithoutCounterSuffixes.
+	WithoutCounterSuffixes bool
+	// ParseUnits spells out units which the OTEL collector passes
+	// through as they are: prefixed units, exponents and products,
+	// such as "kW" as "kilowatts" and "m2" as "meters_squared".
+	// Names with such units then differ from the OTEL exporter's.
+	ParseUnits bool
+	// KeepReservedLabels exports attributes named like reserved
+	// labels ("le", "job", ...) as they are, rather than prefixing
+	// them with "exported_" the way Prometheus does when ingesting
+	// them. "le" and "quantile" are still prefixed on histograms and
+	// summaries, which the Prometheus client can't export them on, and
+	// "__name__" always is, as the client rejects any label starting
+	// with "__".
+	KeepReservedLabels bool
+}
+
+// instrumentName maps OTEL naming conventions to
+// Prometheus naming conventions.
+func (n NamingStrategy) instrumentName(name string, typ instrumentType, unitLabel string) string {
+	if !n.UTF8 {
+		name = fixName(name)
+		if name != "" && name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+	}
+	if name == "" {
+		// nothing was left to translate
+		name = "unnamed"
+	}
+
+	// https://github.com/open-telemetry/opentelemetry-collector-contrib/tree/main/pkg/translator/prometheus#metric-name
+
+	addCounterSuffix := typ == instrumentTypeCounter && !n.WithoutCounterSuffixes
+	if addCounterSuffix {
+		// re-added after the unit
+		name = strings.TrimSuffix(name, "_total")
+	}
+
+	if !n.WithoutUnits {
+		unitStr := translateUnit(unitLabel, typ, n.ParseUnits)
+
+		if unitStr != "" && !strings.HasSuffix(name, "_"+unitStr) {
+			name = name + "_" + unitStr
+		}
+	}
+
+	if addCounterSuffix {
+		name = name + "_total"
+	}
+
+	return name
+}
+
+// metricUnit returns the OpenMetrics unit of a metric named name, or
+// "" if it has none. OpenMetrics requires the unit to be a suffix of
+// the name, so there's no unit if units are left out of names.
+func (n NamingStrategy) metricUnit(name string, typ instrumentType, unitLabel string) string {
+	if n.WithoutUnits {
+		return ""
+	}
+	unitStr := translateUnit(unitLabel, typ, n.ParseUnits)
+	if typ == instrumentTypeCounter {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	if unitStr == "" || !strings.HasSuffix(name, "_"+unitStr) {
+		return ""
+	}
+	return unitStr
+}
+
+// reservedLabels are label names which the Prometheus client or
+// server give a meaning to. "le" and "quantile" break registration
+// of histograms and summaries, and "job" and "instance" are
+// overwritten at scrape time.
+var reservedLabels = []string{"le", "quantile", "job", "instance", "__name__"}
+
+// labelName maps an attribute key to a Prometheus label name.
+func (n NamingStrategy) labelName(key string) string {
+	switch {
+	case key == "__name__" || slices.Contains(reservedLabels, key) && !n.KeepReservedLabels:
+		key = "exported_" + key
+	case n.UTF8 && strings.HasPrefix(key, "__"):
+		// reserved for Prometheus' own use, and rejected by the
+		// client. fixLabelName handles it for legacy names.
+		key = "exported_" + key
+	}
+	if n.UTF8 {
+		return key
+	}
+	return fixLabelName(key)
+}
+
+var invalidCharRegexp = regexp.MustCompile("[^a-zA-Z0-9_:]")
+var manyUnderneathies = regexp.MustCompile("__+")
+
+func fixName(name string) string {
+	// TODO - don't use regexes
+	name = invalidCharRegexp.ReplaceAllString(name, "_")
+	name = manyUnderneathies.ReplaceAllString(name, "_")
+	return strings.Trim(name, "_")
+}
+
+var invalidLabelCharRegexp = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+func fixLabelName(name string) string {
+	// unlike metric names, adjacent underscores aren't coalesced
+	name = invalidLabelCharRegexp.ReplaceAllString(name, "_")
+
+	// label names can't start with a digit, and a leading "__"
+	// is reserved for Prometheus' own use
+	switch {
+	case name == "" || name[0] == '_':
+		name = "key" + name
+	case name[0] >= '0' && name[0] <= '9':
+		name = "key_" + name
+	}
+	return name
+}
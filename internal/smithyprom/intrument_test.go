@@ -0,0 +1,333 @@
+package smithyprom
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_fixName(t *testing.T) {
+
+	tests := []struct {
+		testName string
+		name     string
+		want     string
+	}{
+		{
+			testName: "basic",
+			name:     "hello_world",
+			want:     "hello_world",
+		},
+		{
+			testName: "adjacent invalid chars",
+			name:     "hello, world",
+			want:     "hello_world",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if got := fixName(tt.name); got != tt.want {
+				t.Errorf("fixName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fixLabelName(t *testing.T) {
+	tests := []struct {
+		testName string
+		name     string
+		want     string
+	}{
+		{
+			testName: "basic",
+			name:     "label_key",
+			want:     "label_key",
+		}, {
+			testName: "add key prefix",
+			name:     "_label_key",
+			want:     "key_label_key",
+		},
+		{
+			testName: "replace invalid chars",
+			name:     "label.key",
+			want:     "label_key",
+		},
+		{
+			testName: "no reserved prefix",
+			name:     ">>label key",
+			want:     "key__label_key",
+		},
+		{
+			testName: "no leading digit",
+			name:     "1st",
+			want:     "key_1st",
+		},
+		{
+			testName: "no colons",
+			name:     "label:key",
+			want:     "label_key",
+		},
+		{
+			testName: "empty",
+			name:     "",
+			want:     "key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if got := fixLabelName(tt.name); got != tt.want {
+				t.Errorf("fixLabelName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservedLabels(t *testing.T) {
+	for _, name := range []string{"le", "quantile", "job", "instance", "__name__"} {
+		t.Run(name, func(t *testing.T) {
+			if got, want := (NamingStrategy{}).labelName(name), "exported_"+name; got != want {
+				t.Errorf("labelName() = %q, want %q", got, want)
+			}
+			if got, want := (NamingStrategy{UTF8: true}).labelName(name), "exported_"+name; got != want {
+				t.Errorf("UTF-8 labelName() = %q, want %q", got, want)
+			}
+			// but "__name__" can't be kept
+			kept := name
+			if name == "__name__" {
+				kept = "exported_" + name
+			}
+			if got := (NamingStrategy{KeepReservedLabels: true}).labelName(name); got != kept {
+				t.Errorf("labelName() keeping reserved labels = %q, want %q", got, kept)
+			}
+			if got := (NamingStrategy{UTF8: true, KeepReservedLabels: true}).labelName(name); got != kept {
+				t.Errorf("UTF-8 labelName() keeping reserved labels = %q, want %q", got, kept)
+			}
+
+			// every reserved name should be usable on a histogram
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry: reg,
+				OnError:  func(err error) { t.Error(err) },
+			})
+			h, err := mp.Meter("test").Float64Histogram("duration", withUnit("s"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Record(context.Background(), 0.5, withAttrs(name, "value"))
+
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mfs) != 1 {
+				t.Fatalf("gathered %d families, want 1", len(mfs))
+			}
+			lbls := mfs[0].GetMetric()[0].GetLabel()
+			if len(lbls) != 1 || lbls[0].GetName() != "exported_"+name || lbls[0].GetValue() != "value" {
+				t.Errorf("got labels %v", lbls)
+			}
+		})
+	}
+}
+
+// kept reserved labels should still gather, with the ones the
+// Prometheus client rejects prefixed
+func TestKeepReservedLabelsGather(t *testing.T) {
+	tests := []struct {
+		testName string
+		naming   NamingStrategy
+		want     []string
+	}{
+		{
+			testName: "legacy",
+			naming:   NamingStrategy{KeepReservedLabels: true},
+			want:     []string{"exported___name__", "instance", "job", "key__x", "le", "quantile"},
+		},
+		{
+			testName: "utf8",
+			naming:   NamingStrategy{UTF8: true, KeepReservedLabels: true},
+			want:     []string{"exported___name__", "exported___x", "instance", "job", "le", "quantile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			useValidationScheme(t, tt.naming.UTF8)
+
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry: reg,
+				Naming:   tt.naming,
+				OnError:  func(err error) { t.Error(err) },
+			})
+			c, err := mp.Meter("test").Int64Counter("calls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Add(context.Background(), 1, withAttrs(
+				"__name__", "value", "__x", "value", "instance", "value",
+				"job", "value", "le", "value", "quantile", "value",
+			))
+
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mfs) != 1 {
+				t.Fatalf("gathered %d families, want 1", len(mfs))
+			}
+			var got []string
+			for _, l := range mfs[0].GetMetric()[0].GetLabel() {
+				got = append(got, l.GetName())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got labels %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// histograms and summaries can't have "le" and "quantile" labels, even
+// when reserved labels are kept
+func TestKeepReservedBucketLabels(t *testing.T) {
+	aggs := map[string]Aggregation{
+		"histogram": AggregationExplicitBucketHistogram{},
+		"summary":   AggregationSummary{},
+	}
+	for aggName, agg := range aggs {
+		for _, name := range []string{"le", "quantile"} {
+			t.Run(aggName+"/"+name, func(t *testing.T) {
+				reg := prometheus.NewRegistry()
+				mp := NewMeterProvider(&Options{
+					Registry: reg,
+					Naming:   NamingStrategy{KeepReservedLabels: true},
+					Views: []View{func(i Instrument) (Stream, bool) {
+						return Stream{Name: i.Name, Aggregation: agg}, true
+					}},
+					OnError: func(err error) { t.Error(err) },
+				})
+				h, err := mp.Meter("test").Float64Histogram("duration", withUnit("s"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.Record(context.Background(), 0.5, withAttrs(name, "value", "job", "value"))
+
+				mfs, err := reg.Gather()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(mfs) != 1 {
+					t.Fatalf("gathered %d families, want 1", len(mfs))
+				}
+				var got []string
+				for _, l := range mfs[0].GetMetric()[0].GetLabel() {
+					got = append(got, l.GetName())
+				}
+				if want := []string{"exported_" + name, "job"}; !slices.Equal(got, want) {
+					t.Errorf("got labels %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// once a series exists, recording to it shouldn't allocate
+func TestRecordAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations aren't predictable under the race detector")
+	}
+	mp := NewMeterProvider(&Options{Registry: prometheus.NewRegistry()})
+	m := mp.Meter("test")
+	ctx := context.Background()
+
+	c, err := m.Int64Counter("client.call.attempts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := m.Float64Histogram("client.call.duration", withUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := m.Int64UpDownCounter("client.http.connections.usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the attributes and options are built up front, as converting
+	// strings to interfaces and building the variadic slice would
+	// allocate on the caller's side
+	attrs := []metrics.RecordMetricOption{staticAttrs("rpc.service", "S3", "rpc.method", "ListBuckets")}
+
+	// context labels with allowed values, and without
+	tmp := NewMeterProvider(&Options{
+		Registry:      prometheus.NewRegistry(),
+		ContextLabels: []ContextLabel{{Key: "tenant", Extract: tenant, Values: []string{"a"}}},
+	})
+	tc, err := tmp.Meter("test").Int64Counter("client.call.attempts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedCtx := withTenant(ctx, "a")
+	otherCtx := withTenant(ctx, "b")
+
+	tests := []struct {
+		testName string
+		record   func()
+	}{
+		{
+			testName: "labeled counter",
+			record:   func() { c.Add(ctx, 1, attrs...) },
+		},
+		{
+			testName: "labeled histogram",
+			record:   func() { h.Record(ctx, 0.1, attrs...) },
+		},
+		{
+			testName: "unlabeled up-down counter",
+			record:   func() { u.Add(ctx, 1) },
+		},
+		{
+			testName: "allowed context label",
+			record:   func() { tc.Add(allowedCtx, 1, attrs...) },
+		},
+		{
+			testName: "other context label",
+			record:   func() { tc.Add(otherCtx, 1, attrs...) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			tt.record()
+			if allocs := testing.AllocsPerRun(100, tt.record); allocs != 0 {
+				t.Errorf("recording allocated %v times per run", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkRecord(b *testing.B) {
+	mp := NewMeterProvider(&Options{Registry: prometheus.NewRegistry()})
+	h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	attrs := []metrics.RecordMetricOption{staticAttrs("rpc.service", "S3", "rpc.method", "ListBuckets")}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Record(ctx, 0.1, attrs...)
+	}
+}
+
+// staticAttrs is withAttrs, but with the attributes converted to
+// interfaces once rather than on every call.
+func staticAttrs(kv ...any) metrics.RecordMetricOption {
+	return func(o *metrics.RecordMetricOptions) {
+		for i := 0; i < len(kv); i += 2 {
+			o.Properties.Set(kv[i], kv[i+1])
+		}
+	}
+}
@@ -0,0 +1,455 @@
+// Package smithyprom provides a "Prometheus native" implementation
+// of the smithy-go (and so AWS SDK) meter-provider interface.
+package smithyprom
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var _ metrics.MeterProvider = (*MeterProvider)(nil)
+
+// A MeterProvider is an adapter which maps
+// Prometheus metrics to the smithy-go metric interfaces.
+//
+// This is tricksy because:
+//
+//   - The AWS SDKs rely on the instrument-caching behavior
+//     of the OTEL meter provider
+//   - Metric-labels are not provided until a metric is
+//     observed.
+//
+// So we do caching and delayed instantiation.
+type MeterProvider struct {
+	prefix string
+	filter func(name string) bool
+	views  []View
+	naming NamingStrategy
+	// collision handling
+	collisions CollisionStrategy
+	onError    func(error)
+	names      metricNames
+	// series expiry, if seriesTTL is set
+	seriesTTL time.Duration
+	clock     Clock
+	minMax    *MinMaxOptions
+	labels    []contextLabel
+	done      chan struct{}
+	closeOnce sync.Once
+	// The OTEL meter-provider caches instruments, and the AWS SDK
+	// assumes this behavior. The prometheus client does not do this
+	// natively.
+	metricCache cache
+	// handles caches the instruments handed to the AWS SDK, which
+	// are backed by the cached streams
+	handles sync.Map
+}
+
+// Options configures a [MeterProvider].
+type Options struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+	// Registry is where metrics are registered. If nil,
+	// [prometheus.DefaultRegisterer] is used.
+	Registry prometheus.Registerer
+	// Filter, if set, is called with each instrument name. Instruments
+	// for which it returns false are not recorded.
+	Filter func(name string) bool
+	// Views customize how instruments are exported. See [View].
+	Views []View
+	// Naming controls how names are translated for Prometheus.
+	Naming NamingStrategy
+	// Collisions is what to do when names collide after
+	// translation. See [CollisionStrategy].
+	Collisions CollisionStrategy
+	// OnError is called with errors which can't be returned to the
+	// AWS SDK, such as name collisions. If nil, errors are logged
+	// with the log package.
+	OnError func(error)
+	// SeriesTTL, if set, is how long a series (a combination of
+	// label values) may go without being recorded to before it's
+	// deleted. Idle series are swept in the background every
+	// SeriesTTL/2, until the provider is closed.
+	SeriesTTL time.Duration
+	// HistogramMinMax, if set, additionally exports the minimum
+	// and maximum of each histogram series, which Prometheus
+	// histograms can't express. See [MinMaxOptions].
+	HistogramMinMax *MinMaxOptions
+	// ContextLabels add attributes to every observation from the
+	// context it's made in. See [ContextLabel].
+	ContextLabels []ContextLabel
+	// Clock is the time source for SeriesTTL and HistogramMinMax.
+	// If nil, the system clock is used.
+	Clock Clock
+}
+
+// NewMeterProvider returns a new [MeterProvider].
+func NewMeterProvider(opts *Options) *MeterProvider {
+
+	r := opts.Registry
+	if r == nil {
+		r = prometheus.DefaultRegisterer
+	}
+
+	var prefix string
+	if opts.Namespace != "" {
+		prefix = opts.Namespace + "_"
+	}
+
+	onError := opts.OnError
+	if onError == nil {
+		onError = logError
+	}
+
+	p := &MeterProvider{
+		prefix:     prefix,
+		filter:     opts.Filter,
+		views:      opts.Views,
+		naming:     opts.Naming,
+		collisions: opts.Collisions,
+		onError:    onError,
+		clock:      opts.Clock,
+		minMax:     opts.HistogramMinMax,
+		labels:     newContextLabels(opts.ContextLabels),
+		done:       make(chan struct{}),
+	}
+	if p.clock == nil {
+		p.clock = systemClock{}
+	}
+
+	if opts.SeriesTTL > 0 {
+		p.seriesTTL = opts.SeriesTTL
+		go p.sweepEvery(p.seriesTTL / 2)
+	}
+
+	// instruments and their labels aren't known until the AWS SDK
+	// uses them, so rather than registering each as it appears we
+	// register one unchecked collector for all of them
+	if err := r.Register(&collector{p}); err != nil {
+		onError(fmt.Errorf("smithyprom: registering collector: %w", err))
+	}
+
+	return p
+}
+
+// collector collects every instrument of a provider.
+type collector struct {
+	p *MeterProvider
+}
+
+// Describe implements prometheus.Collector. Describing nothing makes
+// the collector unchecked.
+func (c *collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, i := range c.p.names.instruments() {
+		i.collect(ch)
+	}
+}
+
+// Gatherer wraps g, which gathers from the provider's registry, to
+// add unit metadata to the provider's metrics. Prometheus collectors
+// have no way of describing units themselves. Units are only exposed
+// in the OpenMetrics and protobuf formats.
+func (p *MeterProvider) Gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		units := p.names.units()
+		for _, mf := range mfs {
+			if u, ok := units[mf.GetName()]; ok {
+				mf.Unit = &u
+			}
+		}
+		return mfs, err
+	})
+}
+
+// Close stops sweeping for idle series. Instruments can still be
+// used after Close.
+func (p *MeterProvider) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// Meter implements metrics.MeterProvider.
+func (a *MeterProvider) Meter(scope string, opts ...metrics.MeterOption) metrics.Meter {
+	// TODO - optionally add scope as a label? It would need to be included in the cache-key
+	return &promMeter{
+		parent: a,
+		scope:  scope,
+	}
+}
+
+var _ metrics.Meter = (*promMeter)(nil)
+
+type promMeter struct {
+	parent *MeterProvider
+	scope  string
+}
+
+// Float64AsyncCounter implements metrics.Meter.
+func (p *promMeter) Float64AsyncCounter(name string, callback metrics.Float64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64AsyncGauge implements metrics.Meter.
+func (p *promMeter) Float64AsyncGauge(name string, callback metrics.Float64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64AsyncUpDownCounter implements metrics.Meter.
+func (p *promMeter) Float64AsyncUpDownCounter(name string, callback metrics.Float64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64Counter implements metrics.Meter.
+func (p *promMeter) Float64Counter(name string, opts ...metrics.InstrumentOption) (metrics.Float64Counter, error) {
+	if s := lookupStreamInstruments[float64](p, name, InstrumentKindCounter, opts); s != nil {
+		return s, nil
+	}
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64Gauge implements metrics.Meter.
+func (p *promMeter) Float64Gauge(name string, opts ...metrics.InstrumentOption) (metrics.Float64Gauge, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64Histogram implements metrics.Meter.
+func (p *promMeter) Float64Histogram(name string, opts ...metrics.InstrumentOption) (metrics.Float64Histogram, error) {
+	if s := lookupStreamInstruments[float64](p, name, InstrumentKindHistogram, opts); s != nil {
+		return s, nil
+	}
+	return &noopInstrument[float64]{}, nil
+}
+
+// Float64UpDownCounter implements metrics.Meter.
+func (p *promMeter) Float64UpDownCounter(name string, opts ...metrics.InstrumentOption) (metrics.Float64UpDownCounter, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Int64AsyncCounter implements metrics.Meter.
+func (p *promMeter) Int64AsyncCounter(name string, callback metrics.Int64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Int64AsyncGauge implements metrics.Meter.
+func (p *promMeter) Int64AsyncGauge(name string, callback metrics.Int64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Int64AsyncUpDownCounter implements metrics.Meter.
+func (p *promMeter) Int64AsyncUpDownCounter(name string, callback metrics.Int64Callback, opts ...metrics.InstrumentOption) (metrics.AsyncInstrument, error) {
+	return &noopInstrument[float64]{}, nil
+}
+
+// Int64Counter implements metrics.Meter.
+func (p *promMeter) Int64Counter(name string, opts ...metrics.InstrumentOption) (metrics.Int64Counter, error) {
+	if s := lookupStreamInstruments[int64](p, name, InstrumentKindCounter, opts); s != nil {
+		return s, nil
+	}
+	return &noopInstrument[int64]{}, nil
+}
+
+// Int64Gauge implements metrics.Meter.
+func (p *promMeter) Int64Gauge(name string, opts ...metrics.InstrumentOption) (metrics.Int64Gauge, error) {
+	return &noopInstrument[int64]{}, nil
+}
+
+// Int64Histogram implements metrics.Meter.
+func (p *promMeter) Int64Histogram(name string, opts ...metrics.InstrumentOption) (metrics.Int64Histogram, error) {
+	return &noopInstrument[int64]{}, nil
+}
+
+// Int64UpDownCounter implements metrics.Meter.
+func (p *promMeter) Int64UpDownCounter(name string, opts ...metrics.InstrumentOption) (metrics.Int64UpDownCounter, error) {
+	if s := lookupStreamInstruments[int64](p, name, InstrumentKindUpDownCounter, opts); s != nil {
+		return s, nil
+	}
+	return &noopInstrument[int64]{}, nil
+}
+
+// lookupStreamInstruments returns the instrument handed to the AWS
+// SDK, using a previously cached one or instantiating and caching a new
+// one. It returns nil if the instrument isn't exported.
+//
+// The AWS SDK looks its instruments up for every operation, so the
+// handles are cached as well as the streams behind them: each handle
+// pools the scratch space for recording.
+func lookupStreamInstruments[T float64 | int64](p *promMeter, name string, kind InstrumentKind, opts []metrics.InstrumentOption) *streamInstruments[T] {
+	o := collectInstrumentOptions(opts)
+	var zero T
+	_, float := any(zero).(float64)
+	k := handleKey{
+		inst: Instrument{
+			Name:        name,
+			Description: o.Description,
+			Kind:        kind,
+			Unit:        o.UnitLabel,
+			Scope:       p.scope,
+		},
+		float: float,
+	}
+
+	if s, ok := p.parent.handles.Load(k); ok {
+		return s.(*streamInstruments[T])
+	}
+
+	var s *streamInstruments[T]
+	if ms := p.getInstrument(k.inst); ms != nil {
+		s = newStreamInstruments[T](ms, contextLabelsFor(p.parent.labels, name))
+	}
+	actual, _ := p.parent.handles.LoadOrStore(k, s)
+	return actual.(*streamInstruments[T])
+}
+
+// handleKey identifies an instrument handed to the AWS SDK.
+type handleKey struct {
+	inst  Instrument
+	float bool
+}
+
+// getInstrument returns the streams for an instrument, using previously
+// cached streams or instantiating and caching new ones. It returns nil
+// if the instrument isn't exported.
+func (p *promMeter) getInstrument(inst Instrument) []*promInstrument {
+	if p.parent.filter != nil && !p.parent.filter(inst.Name) {
+		return nil
+	}
+
+	streams := streamsFor(p.parent.views, inst)
+
+	var ms []*promInstrument
+	for _, s := range streams {
+		agg, buckets := resolveAggregation(s.Aggregation, inst.Kind, inst.Unit)
+		typ := agg.instrumentType()
+		if typ == instrumentTypeSummary && hasHistogram(streams, s.Name, inst) {
+			// so it can be exported alongside the histogram
+			s.Name += "_summary"
+		}
+
+		k := cacheKey{
+			source: inst.Name,
+			name:   s.Name,
+			typ:    typ,
+			unit:   inst.Unit,
+		}
+		if a, ok := s.Aggregation.(AggregationSummary); ok {
+			k.summary = a.key()
+		}
+
+		m := p.parent.metricCache.lookupOrInsert(k, func() *promInstrument {
+			return p.parent.newInstrument(k, s, agg, buckets)
+		})
+		if m != nil {
+			ms = append(ms, m)
+		}
+	}
+
+	return ms
+}
+
+// hasHistogram returns whether any of the streams of inst is a
+// histogram named name.
+func hasHistogram(streams []Stream, name string, inst Instrument) bool {
+	for _, s := range streams {
+		agg, _ := resolveAggregation(s.Aggregation, inst.Kind, inst.Unit)
+		if s.Name == name && agg.instrumentType() == instrumentTypeHistogram {
+			return true
+		}
+	}
+	return false
+}
+
+// newInstrument creates the instrument for a stream, resolving any
+// collision with another instrument's name. It returns nil if the
+// stream can't be exported.
+func (p *MeterProvider) newInstrument(k cacheKey, s Stream, agg aggregation, buckets []float64) *promInstrument {
+	i := &promInstrument{
+		key:             k,
+		name:            p.prefix + p.naming.instrumentName(s.Name, k.typ, k.unit),
+		description:     s.Description,
+		naming:          p.naming,
+		agg:             agg,
+		buckets:         buckets,
+		attributeFilter: s.AttributeFilter,
+		collisions:      p.collisions,
+		onError:         p.onError,
+		clock:           p.clock,
+		expires:         p.seriesTTL > 0,
+		minMax:          p.minMax,
+	}
+
+	if a, ok := s.Aggregation.(AggregationSummary); ok {
+		i.summary = a
+	}
+
+	i.unit = p.naming.metricUnit(i.name, k.typ, k.unit)
+
+	owner, name, ok := p.names.claim(i)
+	if ok {
+		return owner
+	}
+	p.onError(&NameCollisionError{Metric: name, Name: name, First: owner.key.source, Second: k.source})
+
+	switch p.collisions {
+	case CollisionHashSuffix:
+		// hashing the source, as a view may have renamed the
+		// stream to the other instrument's name
+		i.name = p.prefix + p.naming.instrumentName(s.Name+"_"+hashSuffix(k.source), k.typ, k.unit)
+		i.unit = p.naming.metricUnit(i.name, k.typ, k.unit)
+		if owner, _, ok := p.names.claim(i); ok {
+			return owner
+		}
+	case CollisionMerge:
+		// not with the min and max of a histogram
+		if owner.name == i.name && owner.agg == agg && slices.Equal(owner.buckets, buckets) && owner.key.summary == k.summary {
+			return owner
+		}
+	}
+	return nil
+}
+
+// cacheKey identifies a stream. source is the name of the instrument
+// the stream is from, so that a view renaming one instrument to
+// another's name collides with it rather than sharing its stream, and
+// summary is the configuration of summaries, so that differently
+// configured summaries collide too.
+type cacheKey struct {
+	source  string
+	name    string
+	typ     instrumentType
+	unit    string
+	summary string
+}
+
+type cache struct {
+	m sync.Map
+	// mu serializes insertion, so that each instrument is only
+	// made once
+	mu sync.Mutex
+}
+
+func (c *cache) lookupOrInsert(k cacheKey, mk func() *promInstrument) *promInstrument {
+	metricAny, ok := c.m.Load(k)
+	if ok {
+		return metricAny.(*promInstrument)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metricAny, ok = c.m.Load(k)
+	if ok {
+		return metricAny.(*promInstrument)
+	}
+	m := mk()
+	c.m.Store(k, m)
+	return m
+}
@@ -0,0 +1,153 @@
+package smithyprom
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// instruments should show up in the registry as they're used, with
+// no registration of their own
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mps := []*MeterProvider{
+		NewMeterProvider(&Options{Registry: reg, Namespace: "s3", OnError: func(err error) { t.Error(err) }}),
+		NewMeterProvider(&Options{Registry: reg, Namespace: "dynamodb", OnError: func(err error) { t.Error(err) }}),
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader("")); err != nil {
+		t.Error(err)
+	}
+
+	ctx := context.Background()
+	for _, mp := range mps {
+		c, err := mp.Meter("test").Int64Counter("client.call.attempts", withDescription("attempts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// created, but not yet used
+		if _, err := mp.Meter("test").Float64Histogram("client.call.duration"); err != nil {
+			t.Fatal(err)
+		}
+		c.Add(ctx, 1, withAttrs("rpc.method", "List"))
+	}
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP dynamodb_client_call_attempts_total attempts
+# TYPE dynamodb_client_call_attempts_total counter
+dynamodb_client_call_attempts_total{rpc_method="List"} 1
+# HELP s3_client_call_attempts_total attempts
+# TYPE s3_client_call_attempts_total counter
+s3_client_call_attempts_total{rpc_method="List"} 1
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// units are only added to metrics whose names end with them, and
+// counters and histograms have created timestamps
+func TestGathererUnits(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		naming NamingStrategy
+		units  map[string]string
+	}{
+		{
+			name: "default",
+			units: map[string]string{
+				"aws_client_call_duration_seconds":    "seconds",
+				"aws_client_call_payload_bytes_total": "bytes",
+				"aws_client_call_attempts_total":      "",
+				"aws_client_call_in_flight":           "",
+			},
+		},
+		{
+			name:   "without units",
+			naming: NamingStrategy{WithoutUnits: true},
+			units: map[string]string{
+				"aws_client_call_duration":      "",
+				"aws_client_call_payload_total": "",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{Registry: reg, Namespace: "aws", Naming: tt.naming, OnError: func(err error) { t.Error(err) }})
+			m := mp.Meter("test")
+			ctx := context.Background()
+
+			h, _ := m.Float64Histogram("client.call.duration", withUnit("s"))
+			h.Record(ctx, 0.1)
+			c, _ := m.Int64Counter("client.call.payload", withUnit("By"))
+			c.Add(ctx, 10)
+			a, _ := m.Int64Counter("client.call.attempts", withUnit("{attempt}"))
+			a.Add(ctx, 1)
+			u, _ := m.Int64UpDownCounter("client.call.in_flight", withUnit("{operation}"))
+			u.Add(ctx, 1)
+
+			mfs, err := mp.Gatherer(reg).Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string)
+			for _, mf := range mfs {
+				got[mf.GetName()] = mf.GetUnit()
+				for _, m := range mf.Metric {
+					created := m.GetCounter().GetCreatedTimestamp()
+					if created == nil {
+						created = m.GetHistogram().GetCreatedTimestamp()
+					}
+					if m.Gauge == nil && created == nil {
+						t.Errorf("%s: no created timestamp", mf.GetName())
+					}
+				}
+			}
+			for name, want := range tt.units {
+				if u, ok := got[name]; !ok {
+					t.Errorf("%s: not gathered, got %v", name, got)
+				} else if u != want {
+					t.Errorf("%s: got unit %q, want %q", name, u, want)
+				}
+			}
+		})
+	}
+}
+
+// observations without some of the attributes seen are recorded with
+// empty labels, and ones with new attributes get a label-schema of
+// their own
+func TestUnevenAttributes(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{Registry: reg, OnError: func(err error) { t.Error(err) }})
+	c, err := mp.Meter("test").Int64Counter("calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c.Add(ctx, 1, withAttrs("a", "1", "b", "2"))
+	c.Add(ctx, 5, withAttrs("a", "1"))
+	c.Add(ctx, 2, withAttrs("b", "2"))
+	c.Add(ctx, 3)
+	// a new key adds a series with its label
+	c.Add(ctx, 4, withAttrs("a", "1", "c", "3"))
+	// but the others still go to the series they did
+	c.Add(ctx, 6, withAttrs("a", "1"))
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP calls_total
+# TYPE calls_total counter
+calls_total{a="",b=""} 3
+calls_total{a="",b="2"} 2
+calls_total{a="1",b=""} 11
+calls_total{a="1",b="2"} 1
+calls_total{a="1",b="",c="3"} 4
+`))
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,168 @@
+package smithyprom
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MinMaxOptions configures the <name>_min and <name>_max gauges
+// exported alongside histograms.
+type MinMaxOptions struct {
+	// Window is the sliding window the minimum and maximum are
+	// over. It advances in steps of a fifth of Window. If zero, it's
+	// one minute. Scraping doesn't reset it, so any number of
+	// scrapers see the same values.
+	Window time.Duration
+	// ResetOnScrape makes the minimum and maximum of the values
+	// recorded since the previous scrape instead, ignoring Window.
+	// Every scrape starts over, so this only makes sense with a
+	// single scraper: with more, each sees a share of the values.
+	ResetOnScrape bool
+}
+
+// minMaxSteps is how many steps a sliding window advances in.
+const minMaxSteps = 5
+
+// defaultMinMaxWindow is the window if MinMaxOptions doesn't say.
+const defaultMinMaxWindow = time.Minute
+
+// minMaxMetric tracks the minimum and maximum of each series of a
+// histogram.
+type minMaxMetric struct {
+	minDesc *prometheus.Desc
+	maxDesc *prometheus.Desc
+	window  time.Duration
+	// resetOnScrape is MinMaxOptions.ResetOnScrape
+	resetOnScrape bool
+	clock         Clock
+
+	mu     sync.Mutex
+	series []*minMaxTracker
+}
+
+func newMinMaxMetric(i *promInstrument, labelNames []string) *minMaxMetric {
+	window := i.minMax.Window
+	if window == 0 {
+		window = defaultMinMaxWindow
+	}
+	return &minMaxMetric{
+		minDesc:       prometheus.NewDesc(i.name+"_min", "Minimum of "+i.name, labelNames, nil),
+		maxDesc:       prometheus.NewDesc(i.name+"_max", "Maximum of "+i.name, labelNames, nil),
+		window:        window,
+		resetOnScrape: i.minMax.ResetOnScrape,
+		clock:         i.clock,
+	}
+}
+
+func (m *minMaxMetric) child(labelValues []string) *minMaxTracker {
+	t := &minMaxTracker{
+		labelValues:   slices.Clone(labelValues),
+		window:        m.window,
+		resetOnScrape: m.resetOnScrape,
+		clock:         m.clock,
+	}
+	t.reset()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = append(m.series, t)
+	return t
+}
+
+func (m *minMaxMetric) deleteChild(labelValues []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = slices.DeleteFunc(m.series, func(t *minMaxTracker) bool {
+		return slices.Equal(t.labelValues, labelValues)
+	})
+}
+
+func (m *minMaxMetric) collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	series := slices.Clone(m.series)
+	m.mu.Unlock()
+
+	for _, t := range series {
+		lo, hi, ok := t.collect()
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(m.minDesc, prometheus.GaugeValue, lo, t.labelValues...)
+		ch <- prometheus.MustNewConstMetric(m.maxDesc, prometheus.GaugeValue, hi, t.labelValues...)
+	}
+}
+
+// minMaxTracker tracks the minimum and maximum of one series.
+type minMaxTracker struct {
+	labelValues   []string
+	window        time.Duration
+	resetOnScrape bool
+	clock         Clock
+
+	mu sync.Mutex
+	// steps of the sliding window, or only the first if it's reset
+	// on scrape
+	steps [minMaxSteps]minMaxStep
+}
+
+// minMaxStep is the minimum and maximum over one step of a window.
+type minMaxStep struct {
+	// start is which step this is, counting from the Unix epoch
+	start    int64
+	min, max float64
+}
+
+func (t *minMaxTracker) reset() {
+	for i := range t.steps {
+		t.steps[i] = minMaxStep{start: -1, min: math.Inf(1), max: math.Inf(-1)}
+	}
+}
+
+// step returns the step of the window now is in.
+func (t *minMaxTracker) step(now time.Time) int64 {
+	return now.UnixNano() / max(int64(t.window/minMaxSteps), 1)
+}
+
+func (t *minMaxTracker) observe(v float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &t.steps[0]
+	if !t.resetOnScrape {
+		n := t.step(t.clock.Now())
+		s = &t.steps[n%minMaxSteps]
+		if s.start != n {
+			*s = minMaxStep{start: n, min: math.Inf(1), max: math.Inf(-1)}
+		}
+	}
+	s.min = min(s.min, v)
+	s.max = max(s.max, v)
+}
+
+// collect returns the minimum and maximum, and false if nothing has
+// been recorded within the window. If it's reset on scrape, it
+// starts over.
+func (t *minMaxTracker) collect() (float64, float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.resetOnScrape {
+		s := t.steps[0]
+		t.reset()
+		return s.min, s.max, s.min <= s.max
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	n := t.step(t.clock.Now())
+	for _, s := range t.steps {
+		if s.start > n-minMaxSteps {
+			lo = min(lo, s.min)
+			hi = max(hi, s.max)
+		}
+	}
+	return lo, hi, lo <= hi
+}
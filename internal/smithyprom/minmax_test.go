@@ -0,0 +1,203 @@
+package smithyprom
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHistogramMinMaxDefaultWindow(t *testing.T) {
+	clock := newFakeClock()
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:        reg,
+		HistogramMinMax: &MinMaxOptions{},
+		Clock:           clock,
+	})
+	h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	attrs := withAttrs("rpc.service", "S3")
+
+	h.Record(ctx, 0.2, attrs)
+	h.Record(ctx, 3, attrs)
+	h.Record(ctx, 0.7, attrs)
+
+	want := `
+# HELP client_call_duration_seconds_max Maximum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_max gauge
+client_call_duration_seconds_max{rpc_service="S3"} 3
+# HELP client_call_duration_seconds_min Minimum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_min gauge
+client_call_duration_seconds_min{rpc_service="S3"} 0.2
+`
+	names := []string{"client_call_duration_seconds_min", "client_call_duration_seconds_max"}
+	// scraping doesn't reset the window, so a second scraper sees
+	// the same values
+	for range 2 {
+		if err := testutil.GatherAndCompare(reg, strings.NewReader(want), names...); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// the window is a minute
+	clock.advance(2 * time.Minute)
+	assertNoFamilies(t, reg, names...)
+}
+
+// the min and max metrics' names collide with other instruments'
+func TestHistogramMinMaxCollisions(t *testing.T) {
+	var errs []error
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:        reg,
+		HistogramMinMax: &MinMaxOptions{},
+		OnError:         func(err error) { errs = append(errs, err) },
+	})
+	m := mp.Meter("test")
+	ctx := context.Background()
+
+	h, _ := m.Float64Histogram("duration")
+	h.Record(ctx, 1)
+	c, _ := m.Int64UpDownCounter("duration.min")
+	c.Add(ctx, 2)
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP duration_max Maximum of duration
+# TYPE duration_max gauge
+duration_max 1
+# HELP duration_min Minimum of duration
+# TYPE duration_min gauge
+duration_min 1
+`), "duration_min", "duration_max"); err != nil {
+		t.Error(err)
+	}
+
+	var collision *NameCollisionError
+	if len(errs) != 1 || !errors.As(errs[0], &collision) {
+		t.Fatalf("got errors %v, want one collision", errs)
+	}
+	if collision.Name != "duration_min" || collision.First != "duration" || collision.Second != "duration.min" {
+		t.Errorf("got collision %+v", collision)
+	}
+}
+
+func TestHistogramMinMaxWindow(t *testing.T) {
+	clock := newFakeClock()
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:        reg,
+		HistogramMinMax: &MinMaxOptions{Window: 5 * time.Minute},
+		Clock:           clock,
+	})
+	h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	h.Record(ctx, 5)
+	clock.advance(2 * time.Minute)
+	h.Record(ctx, 1)
+	h.Record(ctx, 2)
+
+	want := `
+# HELP client_call_duration_seconds_max Maximum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_max gauge
+client_call_duration_seconds_max 5
+# HELP client_call_duration_seconds_min Minimum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_min gauge
+client_call_duration_seconds_min 1
+`
+	names := []string{"client_call_duration_seconds_min", "client_call_duration_seconds_max"}
+	// scraping doesn't reset a window
+	for range 2 {
+		if err := testutil.GatherAndCompare(reg, strings.NewReader(want), names...); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// the first observation has left the window
+	clock.advance(4 * time.Minute)
+	want = `
+# HELP client_call_duration_seconds_max Maximum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_max gauge
+client_call_duration_seconds_max 2
+# HELP client_call_duration_seconds_min Minimum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_min gauge
+client_call_duration_seconds_min 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+
+	// and then everything has
+	clock.advance(4 * time.Minute)
+	assertNoFamilies(t, reg, names...)
+}
+
+// reset on scrape, each scrape has the values recorded since the last
+func TestHistogramMinMaxResetOnScrape(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry:        reg,
+		HistogramMinMax: &MinMaxOptions{ResetOnScrape: true},
+	})
+	h, err := mp.Meter("test").Float64Histogram("client.call.duration", withUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	names := []string{"client_call_duration_seconds_min", "client_call_duration_seconds_max"}
+
+	h.Record(ctx, 5)
+	h.Record(ctx, 1)
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_duration_seconds_max Maximum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_max gauge
+client_call_duration_seconds_max 5
+# HELP client_call_duration_seconds_min Minimum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_min gauge
+client_call_duration_seconds_min 1
+`), names...)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// nothing since the last scrape
+	assertNoFamilies(t, reg, names...)
+
+	h.Record(ctx, 3)
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP client_call_duration_seconds_max Maximum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_max gauge
+client_call_duration_seconds_max 3
+# HELP client_call_duration_seconds_min Minimum of client_call_duration_seconds
+# TYPE client_call_duration_seconds_min gauge
+client_call_duration_seconds_min 3
+`), names...)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func assertNoFamilies(t *testing.T, reg prometheus.Gatherer, names ...string) {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if slices.Contains(names, mf.GetName()) {
+			t.Errorf("unexpected %s", mf.GetName())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package smithyprom
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+// Inputs which have broken name translation before. Fuzzing adds
+// anything it finds to testdata/fuzz.
+var fuzzNameSeeds = []string{
+	"client.call.duration",
+	"hello, world",
+	">>label key",
+	"_label_key",
+	"label:key",
+	"1st",
+	"",
+	"_",
+	"...",
+	"__name__",
+	"le",
+	"a..b__c",
+	"\xff\xfe",
+	"日本語",
+	"a_total",
+}
+
+func Fuzz_fixName(f *testing.F) {
+	for _, s := range fuzzNameSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		got := fixName(name)
+		if strings.ContainsFunc(got, func(r rune) bool { return !isNameRune(r) }) {
+			t.Errorf("fixName(%q) = %q, has invalid characters", name, got)
+		}
+		if strings.Contains(got, "__") || strings.HasPrefix(got, "_") || strings.HasSuffix(got, "_") {
+			t.Errorf("fixName(%q) = %q, has stray underscores", name, got)
+		}
+		if again := fixName(got); again != got {
+			t.Errorf("fixName(%q) = %q, but fixName(%q) = %q", name, got, got, again)
+		}
+	})
+}
+
+func Fuzz_instrumentName(f *testing.F) {
+	for _, s := range fuzzNameSeeds {
+		f.Add(s, "s")
+		f.Add(s, "{request}")
+	}
+	f.Fuzz(func(t *testing.T, name, unit string) {
+		for _, typ := range []instrumentType{instrumentTypeCounter, instrumentTypeGauge, instrumentTypeHistogram, instrumentTypeSummary} {
+			got := NamingStrategy{}.instrumentName(name, typ, unit)
+			if !model.IsValidLegacyMetricName(got) {
+				t.Errorf("instrumentName(%q, %v, %q) = %q, not a valid metric name", name, typ, unit, got)
+			}
+		}
+	})
+}
+
+func Fuzz_fixLabelName(f *testing.F) {
+	for _, s := range fuzzNameSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		got := fixLabelName(name)
+		if !model.LabelName(got).IsValidLegacy() {
+			t.Errorf("fixLabelName(%q) = %q, not a valid label name", name, got)
+		}
+		if strings.HasPrefix(got, model.ReservedLabelPrefix) {
+			t.Errorf("fixLabelName(%q) = %q, has the reserved prefix", name, got)
+		}
+		if again := fixLabelName(got); again != got {
+			t.Errorf("fixLabelName(%q) = %q, but fixLabelName(%q) = %q", name, got, got, again)
+		}
+
+		lbl := NamingStrategy{}.labelName(name)
+		if !model.LabelName(lbl).IsValidLegacy() || strings.HasPrefix(lbl, model.ReservedLabelPrefix) || slices.Contains(reservedLabels, lbl) {
+			t.Errorf("labelName(%q) = %q, not a usable label name", name, lbl)
+		}
+	})
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':'
+}
@@ -0,0 +1,133 @@
+package smithyprom
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics/smithyotelmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"demo/internal/otelexport"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var namingStrategies = []struct {
+	testName string
+	naming   NamingStrategy
+}{
+	{
+		testName: "legacy",
+	},
+	{
+		testName: "utf8",
+		naming:   NamingStrategy{UTF8: true},
+	},
+	{
+		testName: "without_units",
+		naming:   NamingStrategy{WithoutUnits: true},
+	},
+	{
+		testName: "without_counter_suffixes",
+		naming:   NamingStrategy{WithoutCounterSuffixes: true},
+	},
+	{
+		testName: "utf8_bare",
+		naming:   NamingStrategy{UTF8: true, WithoutUnits: true, WithoutCounterSuffixes: true},
+	},
+}
+
+// useValidationScheme sets the global Prometheus name-validation
+// scheme for the duration of a test.
+func useValidationScheme(t *testing.T, utf8 bool) {
+	if !utf8 {
+		return
+	}
+	prev := model.NameValidationScheme
+	model.NameValidationScheme = model.UTF8Validation
+	t.Cleanup(func() { model.NameValidationScheme = prev })
+}
+
+func TestNamingGolden(t *testing.T) {
+	for _, tt := range namingStrategies {
+		t.Run(tt.testName, func(t *testing.T) {
+			useValidationScheme(t, tt.naming.UTF8)
+
+			reg := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:  reg,
+				Namespace: "aws",
+				Naming:    tt.naming,
+			})
+			for _, s := range equivalenceScenarios {
+				s.run(t, mp)
+			}
+
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			enc := expfmt.NewEncoder(&got, expfmt.NewFormat(expfmt.TypeTextPlain).WithEscapingScheme(model.NoEscaping))
+			for _, mf := range mfs {
+				if err := enc.Encode(mf); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			golden := filepath.Join("testdata", "naming", tt.testName+".txt")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("output differs from %s (run with -update to accept):\n%s", golden, got.String())
+			}
+		})
+	}
+}
+
+// each strategy should match the corresponding OTEL exporter options
+func TestNamingEquivalence(t *testing.T) {
+	for _, tt := range namingStrategies {
+		t.Run(tt.testName, func(t *testing.T) {
+			useValidationScheme(t, tt.naming.UTF8)
+
+			promRegistry := prometheus.NewRegistry()
+			mp := NewMeterProvider(&Options{
+				Registry:  promRegistry,
+				Namespace: "aws",
+				Naming:    tt.naming,
+			})
+
+			otelRegistry := prometheus.NewRegistry()
+			otelProvider, err := otelexport.NewMeterProvider(otelRegistry, &otelexport.Options{
+				WithoutUnits:           tt.naming.WithoutUnits,
+				WithoutCounterSuffixes: tt.naming.WithoutCounterSuffixes,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			otelMP := smithyotelmetrics.Adapt(otelProvider)
+
+			for _, s := range equivalenceScenarios {
+				s.run(t, mp)
+				s.run(t, otelMP)
+			}
+
+			for _, d := range diffRegistries(t, promRegistry, otelRegistry) {
+				t.Errorf("native vs otel: %s", d)
+			}
+		})
+	}
+}
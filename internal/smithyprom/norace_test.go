@@ -0,0 +1,5 @@
+//go:build !race
+
+package smithyprom
+
+const raceEnabled = false
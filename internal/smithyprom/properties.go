@@ -0,0 +1,19 @@
+package smithyprom
+
+import (
+	"unsafe"
+
+	"github.com/aws/smithy-go"
+)
+
+// smithy.Properties must be nothing but its map, for propertiesMap.
+// This fails to compile if it gains another field.
+var _ [unsafe.Sizeof(smithy.Properties{}) - unsafe.Sizeof(map[any]any(nil))]struct{}
+var _ [unsafe.Sizeof(map[any]any(nil)) - unsafe.Sizeof(smithy.Properties{})]struct{}
+
+// propertiesMap returns the map behind props, which may be nil.
+// Properties only has Values to range over its keys, and that copies
+// the map, which is too slow for every observation.
+func propertiesMap(props *smithy.Properties) map[any]any {
+	return *(*map[any]any)(unsafe.Pointer(props))
+}
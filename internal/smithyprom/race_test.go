@@ -0,0 +1,7 @@
+//go:build race
+
+package smithyprom
+
+// the race detector randomly drops pooled items, so allocations
+// aren't predictable
+const raceEnabled = true
@@ -0,0 +1,194 @@
+package smithyprom
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// These are most useful with -race.
+
+// countingRegisterer counts registrations.
+type countingRegisterer struct {
+	prometheus.Registerer
+	mu    sync.Mutex
+	count int
+}
+
+func (r *countingRegisterer) Register(c prometheus.Collector) error {
+	r.mu.Lock()
+	r.count++
+	r.mu.Unlock()
+	return r.Registerer.Register(c)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	const (
+		goroutines = 32
+		iterations = 200
+	)
+	services := []string{"S3", "DynamoDB", "SQS"}
+	methods := []string{"List", "Get", "Put", "Delete"}
+
+	reg := prometheus.NewRegistry()
+	counting := &countingRegisterer{Registerer: reg}
+	mp := NewMeterProvider(&Options{
+		Registry: counting,
+		OnError:  func(err error) { t.Error(err) },
+	})
+
+	// every goroutine should get the same streams
+	var mu sync.Mutex
+	streams := map[string]map[*promInstrument]bool{}
+	seen := func(name string, inst any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if streams[name] == nil {
+			streams[name] = map[*promInstrument]bool{}
+		}
+		for _, i := range inst.(*streamInstruments[int64]).streams {
+			streams[name][i] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			for i := 0; i < iterations; i++ {
+				// the AWS SDK gets a meter and its instruments per call
+				m := mp.Meter("test")
+				attrs := withAttrs("rpc.service", services[(g+i)%len(services)], "rpc.method", methods[i%len(methods)])
+
+				c, err := m.Int64Counter("client.call.attempts")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				c.Add(ctx, 1, attrs)
+				seen("client.call.attempts", c)
+
+				u, err := m.Int64UpDownCounter("client.http.connections.usage")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// up by one overall, so the end value is
+				// the count, as for the other instruments
+				u.Add(ctx, 2, attrs)
+				u.Add(ctx, -1, attrs)
+				seen("client.http.connections.usage", u)
+
+				h, err := m.Float64Histogram("client.call.duration", withUnit("s"))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				h.Record(ctx, 0.1, attrs)
+
+				// scrape while recording
+				if i%50 == 0 {
+					if _, err := reg.Gather(); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for name, s := range streams {
+		if len(s) != 1 {
+			t.Errorf("%s: got %d streams, want 1", name, len(s))
+		}
+	}
+	if counting.count != 1 {
+		t.Errorf("registered %d collectors, want 1", counting.count)
+	}
+	if n := len(mp.names.instruments()); n != 3 {
+		t.Errorf("made %d instruments, want 3", n)
+	}
+
+	// work out what every series should have ended up with
+	want := map[string]float64{}
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < iterations; i++ {
+			want[fmt.Sprintf("%s/%s", services[(g+i)%len(services)], methods[i%len(methods)])]++
+		}
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range familiesByName(mfs) {
+		got := map[string]float64{}
+		for _, m := range mf.GetMetric() {
+			var svc, method string
+			for _, lp := range m.GetLabel() {
+				switch lp.GetName() {
+				case "rpc_service":
+					svc = lp.GetValue()
+				case "rpc_method":
+					method = lp.GetValue()
+				}
+			}
+			k := svc + "/" + method
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				got[k] = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				got[k] = m.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				got[k] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d series, want %d", mf.GetName(), len(got), len(want))
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s{%s} = %v, want %v", mf.GetName(), k, got[k], v)
+			}
+		}
+	}
+}
+
+// instruments made concurrently by many meters should still be one
+// instrument
+func TestConcurrentInstrumentCreation(t *testing.T) {
+	mp := NewMeterProvider(&Options{Registry: prometheus.NewRegistry()})
+
+	const goroutines = 64
+	got := make([]metrics.Int64Counter, goroutines)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			c, err := mp.Meter("test").Int64Counter("client.call.attempts")
+			if err != nil {
+				t.Error(err)
+			}
+			got[g] = c
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	first := got[0].(*streamInstruments[int64]).streams[0]
+	for _, c := range got[1:] {
+		if c.(*streamInstruments[int64]).streams[0] != first {
+			t.Fatal("concurrent callers got different instruments")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package smithyprom
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultObjectives are the quantiles a summary exports if its view
+// doesn't say.
+var defaultObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+// key returns a comparable form of a's configuration, so that streams
+// of the same name with different configurations aren't merged.
+func (a AggregationSummary) key() string {
+	objectives := a.Objectives
+	if objectives == nil {
+		objectives = defaultObjectives
+	}
+	var b strings.Builder
+	for _, q := range slices.Sorted(maps.Keys(objectives)) {
+		fmt.Fprintf(&b, "%g:%g,", q, objectives[q])
+	}
+	fmt.Fprintf(&b, "%s,%d", a.MaxAge, a.AgeBuckets)
+	return b.String()
+}
+
+// summaryMetric exports a summary.
+type summaryMetric struct {
+	*prometheus.SummaryVec
+}
+
+func newSummaryMetric(i *promInstrument, labelNames []string) *summaryMetric {
+	objectives := i.summary.Objectives
+	if objectives == nil {
+		objectives = defaultObjectives
+	}
+	return &summaryMetric{
+		prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name:       i.name,
+				Help:       i.description,
+				Objectives: objectives,
+				MaxAge:     i.summary.MaxAge,
+				AgeBuckets: i.summary.AgeBuckets,
+			},
+			labelNames,
+		),
+	}
+}
+
+func (m *summaryMetric) child(labelValues []string) series {
+	return summarySeries{m.WithLabelValues(labelValues...)}
+}
+
+func (m *summaryMetric) deleteChild(labelValues []string) {
+	m.DeleteLabelValues(labelValues...)
+}
+
+type summarySeries struct {
+	prometheus.Observer
+}
+
+func (s summarySeries) record(v float64) {
+	s.Observe(v)
+}
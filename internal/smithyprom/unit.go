@@ -0,0 +1,261 @@
+package smithyprom
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Units are UCUM (https://ucum.org) strings, as used by OTEL. They're
+// translated to the Prometheus unit suffix the OTEL collector would
+// use. Units the collector passes through as-is (prefixed units,
+// exponents, products) are passed through too, unless parse is set,
+// in which case "kW" becomes "kilowatts" rather than "kW".
+
+// translateUnit returns the Prometheus name-suffix for a UCUM unit,
+// or the empty string if the unit shouldn't add a suffix.
+func translateUnit(s string, typ instrumentType, parse bool) string {
+	s = stripAnnotations(s)
+	if s == "1" {
+		if typ == instrumentTypeGauge {
+			return "ratio"
+		}
+		return ""
+	}
+
+	// UCUM division is left-associative: "m/s/s" is meters per
+	// second per second
+	terms := strings.Split(s, "/")
+
+	var parts []string
+	if main := translateTerm(terms[0], false, parse); main != "" {
+		parts = append(parts, main)
+	}
+	for _, t := range terms[1:] {
+		if per := translateTerm(t, true, parse); per != "" {
+			parts = append(parts, "per_"+per)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// stripAnnotations removes "{...}" annotations, which carry no
+// unit, along with any surrounding space. An unterminated
+// annotation runs to the end of the string.
+func stripAnnotations(s string) string {
+	var sb strings.Builder
+	for {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			sb.WriteString(s)
+			break
+		}
+		sb.WriteString(s[:open])
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			break
+		}
+		s = s[open+end+1:]
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// translateTerm translates a unit with no division. Units in the
+// denominator of a ratio are singular.
+func translateTerm(s string, per, parse bool) string {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "1" {
+		return ""
+	}
+	if per {
+		if u, ok := perUnitTranslationMap[s]; ok {
+			return u
+		}
+	} else if u, ok := unitTranslationMap[s]; ok {
+		return u
+	}
+	if !parse {
+		return fixName(s)
+	}
+
+	// products, such as "kW.h"
+	factors := strings.Split(s, ".")
+	names := make([]string, 0, len(factors))
+	for i, f := range factors {
+		// only the last factor is plural: "kilowatt_hours"
+		name, ok := translateFactor(f, per || i < len(factors)-1)
+		if !ok {
+			return fixName(s)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "_")
+}
+
+// translateFactor translates a single, possibly prefixed, atom with
+// an optional exponent, such as "cm2" or "s-1".
+func translateFactor(s string, singular bool) (string, bool) {
+	atom, exp := splitExponent(s)
+	if atom == "" || exp == 0 {
+		return "", false
+	}
+
+	u, ok := lookupAtom(atom)
+	if !ok {
+		return "", false
+	}
+
+	// a negative exponent divides: "s-1" is "per_second"
+	name := u.plural
+	if singular || exp < 0 {
+		name = u.singular
+	}
+	switch abs := max(exp, -exp); abs {
+	case 1:
+	case 2:
+		name += "_squared"
+	case 3:
+		name += "_cubed"
+	default:
+		name += "_pow" + strconv.Itoa(abs)
+	}
+	if exp < 0 {
+		name = "per_" + name
+	}
+	return name, true
+}
+
+// splitExponent splits a trailing, optionally negative, integer
+// exponent from s. The exponent is 1 if there isn't one.
+func splitExponent(s string) (string, int) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	if i == len(s) {
+		return s, 1
+	}
+	if i > 0 && (s[i-1] == '-' || s[i-1] == '+') {
+		i--
+	}
+	exp, err := strconv.Atoi(s[i:])
+	if err != nil {
+		return s, 0
+	}
+	return s[:i], exp
+}
+
+// lookupAtom finds a unit atom, with or without a prefix.
+func lookupAtom(s string) (unitAtom, bool) {
+	if u, ok := unitAtoms[s]; ok {
+		return u, true
+	}
+	for prefix, name := range unitPrefixes {
+		atom, ok := strings.CutPrefix(s, prefix)
+		if !ok {
+			continue
+		}
+		u, ok := unitAtoms[atom]
+		if !ok || !u.metric {
+			continue
+		}
+		return unitAtom{
+			singular: name + u.singular,
+			plural:   name + u.plural,
+			metric:   true,
+		}, true
+	}
+	return unitAtom{}, false
+}
+
+// unitTranslationMap and perUnitTranslationMap are the OTEL
+// collector's tables, and take precedence over parsing.
+var unitTranslationMap = map[string]string{
+	"d":   "days",
+	"h":   "hours",
+	"min": "minutes",
+	"s":   "seconds",
+	"ms":  "milliseconds",
+	"us":  "microseconds",
+	"ns":  "nanoseconds",
+
+	"By":   "bytes",
+	"KiBy": "kibibytes",
+	"MiBy": "mebibytes",
+	"GiBy": "gibibytes",
+	"TiBy": "tibibytes",
+	"KBy":  "kilobytes",
+	"MBy":  "megabytes",
+	"GBy":  "gigabytes",
+	"TBy":  "terabytes",
+
+	"m": "meters",
+	"V": "volts",
+	"A": "amperes",
+	"J": "joules",
+	"W": "watts",
+	"g": "grams",
+
+	"Cel": "celsius",
+	"Hz":  "hertz",
+	"%":   "percent",
+}
+
+var perUnitTranslationMap = map[string]string{
+	"s":  "second",
+	"m":  "minute",
+	"h":  "hour",
+	"d":  "day",
+	"w":  "week",
+	"mo": "month",
+	"y":  "year",
+}
+
+type unitAtom struct {
+	singular string
+	plural   string
+	// metric is true if the atom takes a prefix
+	metric bool
+}
+
+var unitAtoms = map[string]unitAtom{
+	"s":   {"second", "seconds", true},
+	"min": {"minute", "minutes", false},
+	"h":   {"hour", "hours", false},
+	"d":   {"day", "days", false},
+	"wk":  {"week", "weeks", false},
+	"mo":  {"month", "months", false},
+	"a":   {"year", "years", false},
+
+	"By":  {"byte", "bytes", true},
+	"bit": {"bit", "bits", true},
+
+	"m":   {"meter", "meters", true},
+	"g":   {"gram", "grams", true},
+	"V":   {"volt", "volts", true},
+	"A":   {"ampere", "amperes", true},
+	"J":   {"joule", "joules", true},
+	"W":   {"watt", "watts", true},
+	"Hz":  {"hertz", "hertz", true},
+	"Cel": {"celsius", "celsius", false},
+	"%":   {"percent", "percent", false},
+}
+
+// unitPrefixes are the UCUM prefixes. They're all distinct, and
+// none is a prefix of another followed by an atom, so the order
+// they're tried in doesn't matter.
+var unitPrefixes = map[string]string{
+	"p":  "pico",
+	"n":  "nano",
+	"u":  "micro",
+	"m":  "milli",
+	"c":  "centi",
+	"k":  "kilo",
+	"M":  "mega",
+	"G":  "giga",
+	"T":  "tera",
+	"P":  "peta",
+	"Ki": "kibi",
+	"Mi": "mebi",
+	"Gi": "gibi",
+	"Ti": "tebi",
+}
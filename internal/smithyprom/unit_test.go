@@ -0,0 +1,96 @@
+package smithyprom
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_translateUnit(t *testing.T) {
+	tests := []struct {
+		unit  string
+		typ   instrumentType
+		parse bool
+		want  string
+	}{
+		// the OTEL collector's table
+		{unit: "s", want: "seconds"},
+		{unit: "ms", want: "milliseconds"},
+		{unit: "By", want: "bytes"},
+		{unit: "KiBy", want: "kibibytes"},
+		{unit: "V", want: "volts"},
+		{unit: "Cel", want: "celsius"},
+		{unit: "%", want: "percent"},
+
+		// dimensionless
+		{unit: "1", want: ""},
+		{unit: "1", typ: instrumentTypeGauge, want: "ratio"},
+		{unit: "", want: ""},
+
+		// annotations
+		{unit: "{request}", want: ""},
+		{unit: "ms{latency}", want: "milliseconds"},
+		{unit: "{packet}/s", want: "per_second"},
+		{unit: "{unterminated", want: ""},
+
+		// ratios
+		{unit: "By/s", want: "bytes_per_second"},
+		{unit: "KiBy/s", want: "kibibytes_per_second"},
+		{unit: "1/s", want: "per_second"},
+		{unit: "m/s/s", want: "meters_per_second_per_second"},
+		{unit: "By/ms", want: "bytes_per_ms"},
+		{unit: "By/ms", parse: true, want: "bytes_per_millisecond"},
+		{unit: "By / s", want: "bytes_per_second"},
+
+		// like the OTEL collector, other units are passed through
+		{unit: "kW", want: "kW"},
+		{unit: "GiBy/s", want: "gibibytes_per_second"},
+		{unit: "m2", want: "m2"},
+		{unit: "m/s2", want: "meters_per_s2"},
+		{unit: "kW.h", want: "kW_h"},
+
+		// unless they're parsed: prefixes
+		{unit: "kW", parse: true, want: "kilowatts"},
+		{unit: "Mbit", parse: true, want: "megabits"},
+		{unit: "GiBy/s", parse: true, want: "gibibytes_per_second"},
+		{unit: "mm", parse: true, want: "millimeters"},
+		{unit: "ks", parse: true, want: "kiloseconds"},
+
+		// exponents and products
+		{unit: "m2", parse: true, want: "meters_squared"},
+		{unit: "cm3", parse: true, want: "centimeters_cubed"},
+		{unit: "m/s2", parse: true, want: "meters_per_second_squared"},
+		{unit: "s-1", parse: true, want: "per_second"},
+		{unit: "m4", parse: true, want: "meters_pow4"},
+		{unit: "kW.h", parse: true, want: "kilowatt_hours"},
+
+		// unknown units are passed through
+		{unit: "widgets", parse: true, want: "widgets"},
+		{unit: "kmin", parse: true, want: "kmin"},
+		{unit: "foo-bar", parse: true, want: "foo_bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			if got := translateUnit(tt.unit, tt.typ, tt.parse); got != tt.want {
+				t.Errorf("translateUnit(%q, parse %v) = %q, want %q", tt.unit, tt.parse, got, tt.want)
+			}
+		})
+	}
+}
+
+var validUnitSuffix = regexp.MustCompile(`^([a-zA-Z0-9:]+(_[a-zA-Z0-9:]+)*)?$`)
+
+func Fuzz_translateUnit(f *testing.F) {
+	for _, s := range []string{"s", "By/s", "{req}/s", "kW.h", "m/s2", "s-1", "1", "KiBy", "ms{x}", "{", "/", ".", "m-", "m99999999999999999999"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, unit string) {
+		for _, typ := range []instrumentType{instrumentTypeCounter, instrumentTypeGauge, instrumentTypeHistogram} {
+			for _, parse := range []bool{false, true} {
+				got := translateUnit(unit, typ, parse)
+				if !validUnitSuffix.MatchString(got) {
+					t.Errorf("translateUnit(%q, parse %v) = %q, not a valid name suffix", unit, parse, got)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package smithyprom
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Views follow the semantics of views in the OTEL metrics SDK
+// (go.opentelemetry.io/otel/sdk/metric), so that the native adapter
+// can be configured the same way as the OTEL adapter:
+//
+//   - every view is consulted for every instrument
+//   - each matching view produces its own stream
+//   - an instrument no view matches is exported with defaults
+
+// A View maps an instrument to the stream it's exported as. It
+// returns false if the view doesn't apply to the instrument.
+type View func(Instrument) (Stream, bool)
+
+// InstrumentKind is the kind of instrument the AWS SDK asked for.
+type InstrumentKind int
+
+const (
+	// InstrumentKindUndefined matches any kind in view criteria.
+	InstrumentKindUndefined InstrumentKind = iota
+	InstrumentKindCounter
+	InstrumentKindUpDownCounter
+	InstrumentKindHistogram
+)
+
+// Instrument describes an instrument. It's both what's passed to
+// a [View], and the match criteria for [NewView].
+type Instrument struct {
+	Name        string
+	Description string
+	Kind        InstrumentKind
+	Unit        string
+	// Scope is the name the meter was created with. The AWS SDK
+	// uses the service package path, such as
+	// "github.com/aws/aws-sdk-go-v2/service/s3".
+	Scope string
+}
+
+// Stream describes how an instrument is exported. Zero-valued
+// fields keep the instrument's own values.
+type Stream struct {
+	// Name replaces the instrument name, before translation to
+	// a Prometheus name.
+	Name        string
+	Description string
+	// AttributeFilter, if set, is called with each attribute key.
+	// Attributes for which it returns false are not exported as
+	// labels.
+	AttributeFilter func(key string) bool
+	Aggregation     Aggregation
+}
+
+// NewAllowKeysFilter returns an attribute filter which only allows
+// the given keys.
+func NewAllowKeysFilter(keys ...string) func(key string) bool {
+	keys = slices.Clone(keys)
+	return func(key string) bool {
+		return slices.Contains(keys, key)
+	}
+}
+
+// An Aggregation overrides how an instrument is exported. It is
+// one of the Aggregation* types in this package.
+type Aggregation interface {
+	isAggregation()
+}
+
+// AggregationDefault exports an instrument the way it would be with
+// no view: counters as counters, up-down counters as gauges and
+// histograms as histograms.
+type AggregationDefault struct{}
+
+// AggregationDrop drops the instrument.
+type AggregationDrop struct{}
+
+// AggregationSum exports the sum of all values. Up-down counters
+// become gauges, everything else becomes a counter.
+type AggregationSum struct{}
+
+// AggregationLastValue exports the most recent value as a gauge.
+type AggregationLastValue struct{}
+
+// AggregationExplicitBucketHistogram exports a histogram with the
+// given bucket boundaries. If none are given, histograms of bytes
+// have [DefByteBuckets] and everything else [prometheus.DefBuckets].
+type AggregationExplicitBucketHistogram struct {
+	Boundaries []float64
+}
+
+// AggregationSummary exports a summary, with quantiles calculated
+// over a sliding window, for dashboards which need client-side
+// quantiles. Summaries can't be aggregated across series, so prefer
+// histograms where possible.
+//
+// If another view exports the same instrument as a histogram of the
+// same name, the summary's name has "_summary" appended, before the
+// unit, so that the two can be exported together. Otherwise it's
+// named like any other stream, so a summary can replace a histogram
+// or be given any name with [Stream.Name].
+type AggregationSummary struct {
+	// Objectives maps quantiles to their allowed absolute error.
+	// If nil, the 50th, 90th and 99th percentiles are exported.
+	Objectives map[float64]float64
+	// MaxAge and AgeBuckets set the sliding window, as in
+	// [prometheus.SummaryOpts]. Zero values use its defaults.
+	MaxAge     time.Duration
+	AgeBuckets uint32
+}
+
+func (AggregationDefault) isAggregation()                 {}
+func (AggregationDrop) isAggregation()                    {}
+func (AggregationSum) isAggregation()                     {}
+func (AggregationLastValue) isAggregation()               {}
+func (AggregationExplicitBucketHistogram) isAggregation() {}
+func (AggregationSummary) isAggregation()                 {}
+
+var errWildcardRename = errors.New("smithyprom: a view matching instruments by wildcard cannot rename them")
+
+// NewView returns a [View] which applies mask to any instrument
+// matching criteria.
+//
+// Zero-valued fields of criteria match anything. criteria.Name may
+// contain the wildcards '*' (any sequence of characters) and '?'
+// (any single character). Description is not matched.
+//
+// It is an error for mask to rename instruments matched by
+// wildcard, as they would all end up with the same name.
+func NewView(criteria Instrument, mask Stream) (View, error) {
+	matchName := func(string) bool { return true }
+	if criteria.Name != "" {
+		if strings.ContainsAny(criteria.Name, "*?") {
+			if mask.Name != "" {
+				return nil, errWildcardRename
+			}
+			re := regexp.MustCompile(globToRegexp(criteria.Name))
+			matchName = re.MatchString
+		} else {
+			matchName = func(name string) bool { return name == criteria.Name }
+		}
+	}
+
+	return func(i Instrument) (Stream, bool) {
+		if !matchName(i.Name) {
+			return Stream{}, false
+		}
+		if criteria.Kind != InstrumentKindUndefined && criteria.Kind != i.Kind {
+			return Stream{}, false
+		}
+		if criteria.Unit != "" && criteria.Unit != i.Unit {
+			return Stream{}, false
+		}
+		if criteria.Scope != "" && criteria.Scope != i.Scope {
+			return Stream{}, false
+		}
+		return mask, true
+	}, nil
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// streamsFor applies views to an instrument, returning the streams it
+// should be exported as. Dropped streams are not returned.
+func streamsFor(views []View, inst Instrument) []Stream {
+	var streams []Stream
+	var matched bool
+	for _, v := range views {
+		s, ok := v(inst)
+		if !ok {
+			continue
+		}
+		matched = true
+		if _, drop := s.Aggregation.(AggregationDrop); drop {
+			continue
+		}
+		streams = append(streams, s)
+	}
+
+	if !matched {
+		streams = append(streams, Stream{})
+	}
+
+	// fill in defaults
+	for i := range streams {
+		s := &streams[i]
+		if s.Name == "" {
+			s.Name = inst.Name
+		}
+		if s.Description == "" {
+			s.Description = inst.Description
+		}
+		if s.Aggregation == nil {
+			s.Aggregation = AggregationDefault{}
+		}
+	}
+
+	return streams
+}
@@ -0,0 +1,384 @@
+package smithyprom
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewView(t *testing.T) {
+	s3Duration := Instrument{
+		Name:  "client.call.duration",
+		Kind:  InstrumentKindHistogram,
+		Unit:  "s",
+		Scope: "github.com/aws/aws-sdk-go-v2/service/s3",
+	}
+
+	tests := []struct {
+		testName string
+		criteria Instrument
+		want     bool
+	}{
+		{
+			testName: "empty criteria",
+			criteria: Instrument{},
+			want:     true,
+		},
+		{
+			testName: "exact name",
+			criteria: Instrument{Name: "client.call.duration"},
+			want:     true,
+		},
+		{
+			testName: "other name",
+			criteria: Instrument{Name: "client.call.attempts"},
+			want:     false,
+		},
+		{
+			testName: "star glob",
+			criteria: Instrument{Name: "client.call.*"},
+			want:     true,
+		},
+		{
+			testName: "question glob",
+			criteria: Instrument{Name: "client.call.duratio?"},
+			want:     true,
+		},
+		{
+			testName: "glob is anchored",
+			criteria: Instrument{Name: "call.*"},
+			want:     false,
+		},
+		{
+			testName: "glob treats dots literally",
+			criteria: Instrument{Name: "client.call*duration"},
+			want:     true,
+		},
+		{
+			testName: "kind",
+			criteria: Instrument{Kind: InstrumentKindCounter},
+			want:     false,
+		},
+		{
+			testName: "unit",
+			criteria: Instrument{Unit: "s"},
+			want:     true,
+		},
+		{
+			testName: "scope",
+			criteria: Instrument{Scope: "github.com/aws/aws-sdk-go-v2/service/dynamodb"},
+			want:     false,
+		},
+		{
+			testName: "everything",
+			criteria: s3Duration,
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			v, err := NewView(tt.criteria, Stream{Description: "matched"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ok := v(s3Duration)
+			if ok != tt.want {
+				t.Fatalf("view matched = %v, want %v", ok, tt.want)
+			}
+			if ok && s.Description != "matched" {
+				t.Errorf("view returned %+v", s)
+			}
+		})
+	}
+}
+
+func TestNewViewWildcardRename(t *testing.T) {
+	_, err := NewView(Instrument{Name: "client.*"}, Stream{Name: "renamed"})
+	if err == nil {
+		t.Error("expected an error renaming a wildcard match")
+	}
+}
+
+func mustView(t *testing.T, criteria Instrument, mask Stream) View {
+	t.Helper()
+	v, err := NewView(criteria, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestViews(t *testing.T) {
+	// every test records the same things
+	record := func(t *testing.T, mp metrics.MeterProvider) {
+		ctx := context.Background()
+		m := mp.Meter("github.com/aws/aws-sdk-go-v2/service/s3")
+
+		h, err := m.Float64Histogram("client.call.duration", withUnit("s"), withDescription("call duration"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Record(ctx, 0.2, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+		h.Record(ctx, 3, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+
+		c, err := m.Int64Counter("client.call.attempts", withUnit("{attempt}"), withDescription("attempts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(ctx, 2, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+		c.Add(ctx, 5, withAttrs("rpc.service", "S3", "rpc.method", "ListBuckets"))
+	}
+
+	tests := []struct {
+		testName string
+		views    func(t *testing.T) []View
+		want     string
+	}{
+		{
+			testName: "drop",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{Aggregation: AggregationDrop{}}),
+				}
+			},
+			want: `
+# HELP client_call_attempts_total attempts
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="ListBuckets",rpc_service="S3"} 7
+`,
+		},
+		{
+			testName: "drop by kind",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindCounter}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Aggregation: AggregationExplicitBucketHistogram{Boundaries: []float64{1}},
+					}),
+				}
+			},
+			want: `
+# HELP client_call_duration_seconds call duration
+# TYPE client_call_duration_seconds histogram
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="1"} 1
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="+Inf"} 2
+client_call_duration_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_duration_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+`,
+		},
+		{
+			testName: "rename and describe",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindHistogram}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.attempts"}, Stream{
+						Name:        "aws.attempts",
+						Description: "attempts per call",
+					}),
+				}
+			},
+			want: `
+# HELP aws_attempts_total attempts per call
+# TYPE aws_attempts_total counter
+aws_attempts_total{rpc_method="ListBuckets",rpc_service="S3"} 7
+`,
+		},
+		{
+			testName: "restrict attributes",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Scope: "github.com/aws/aws-sdk-go-v2/service/s3", Kind: InstrumentKindCounter}, Stream{
+						AttributeFilter: NewAllowKeysFilter("rpc.service"),
+					}),
+					// a drop doesn't stop other views from matching
+					mustView(t, Instrument{Kind: InstrumentKindHistogram}, Stream{Aggregation: AggregationDrop{}}),
+				}
+			},
+			want: `
+# HELP client_call_attempts_total attempts
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_service="S3"} 7
+`,
+		},
+		{
+			testName: "sum and last value",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Name:        "client.call.total_duration",
+						Aggregation: AggregationSum{},
+					}),
+					mustView(t, Instrument{Name: "client.call.attempts"}, Stream{
+						Name:        "client.call.last_attempts",
+						Aggregation: AggregationLastValue{},
+					}),
+				}
+			},
+			want: `
+# HELP client_call_last_attempts attempts
+# TYPE client_call_last_attempts gauge
+client_call_last_attempts{rpc_method="ListBuckets",rpc_service="S3"} 5
+# HELP client_call_total_duration_seconds_total call duration
+# TYPE client_call_total_duration_seconds_total counter
+client_call_total_duration_seconds_total{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+`,
+		},
+		{
+			testName: "one stream per matching view",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindHistogram}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.attempts"}, Stream{}),
+					mustView(t, Instrument{Name: "client.call.attempts"}, Stream{
+						Name:            "client.call.attempts_by_service",
+						AttributeFilter: NewAllowKeysFilter("rpc.service"),
+					}),
+				}
+			},
+			want: `
+# HELP client_call_attempts_by_service_total attempts
+# TYPE client_call_attempts_by_service_total counter
+client_call_attempts_by_service_total{rpc_service="S3"} 7
+# HELP client_call_attempts_total attempts
+# TYPE client_call_attempts_total counter
+client_call_attempts_total{rpc_method="ListBuckets",rpc_service="S3"} 7
+`,
+		},
+		{
+			testName: "histogram and summary",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindCounter}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Aggregation: AggregationExplicitBucketHistogram{Boundaries: []float64{1}},
+					}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Aggregation: AggregationSummary{Objectives: map[float64]float64{0.5: 0.05, 1: 0}},
+					}),
+				}
+			},
+			want: `
+# HELP client_call_duration_seconds call duration
+# TYPE client_call_duration_seconds histogram
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="1"} 1
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="+Inf"} 2
+client_call_duration_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_duration_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+# HELP client_call_duration_summary_seconds call duration
+# TYPE client_call_duration_summary_seconds summary
+client_call_duration_summary_seconds{rpc_method="ListBuckets",rpc_service="S3",quantile="0.5"} 0.2
+client_call_duration_summary_seconds{rpc_method="ListBuckets",rpc_service="S3",quantile="1"} 3
+client_call_duration_summary_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_duration_summary_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+`,
+		},
+		{
+			testName: "summary instead of histogram",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindCounter}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Aggregation: AggregationSummary{Objectives: map[float64]float64{1: 0}},
+					}),
+				}
+			},
+			want: `
+# HELP client_call_duration_seconds call duration
+# TYPE client_call_duration_seconds summary
+client_call_duration_seconds{rpc_method="ListBuckets",rpc_service="S3",quantile="1"} 3
+client_call_duration_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_duration_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+`,
+		},
+		{
+			testName: "renamed summary alongside histogram",
+			views: func(t *testing.T) []View {
+				return []View{
+					mustView(t, Instrument{Kind: InstrumentKindCounter}, Stream{Aggregation: AggregationDrop{}}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Aggregation: AggregationExplicitBucketHistogram{Boundaries: []float64{1}},
+					}),
+					mustView(t, Instrument{Name: "client.call.duration"}, Stream{
+						Name:        "client.call.latency",
+						Aggregation: AggregationSummary{Objectives: map[float64]float64{1: 0}},
+					}),
+				}
+			},
+			want: `
+# HELP client_call_duration_seconds call duration
+# TYPE client_call_duration_seconds histogram
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="1"} 1
+client_call_duration_seconds_bucket{rpc_method="ListBuckets",rpc_service="S3",le="+Inf"} 2
+client_call_duration_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_duration_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+# HELP client_call_latency_seconds call duration
+# TYPE client_call_latency_seconds summary
+client_call_latency_seconds{rpc_method="ListBuckets",rpc_service="S3",quantile="1"} 3
+client_call_latency_seconds_sum{rpc_method="ListBuckets",rpc_service="S3"} 3.2
+client_call_latency_seconds_count{rpc_method="ListBuckets",rpc_service="S3"} 2
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			record(t, NewMeterProvider(&Options{
+				Registry: reg,
+				Views:    tt.views(t),
+			}))
+
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tt.want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// with a single age bucket, the sliding window is reset every MaxAge,
+// rather than an age bucket at a time
+func TestSummaryWindow(t *testing.T) {
+	t.Parallel()
+	reg := prometheus.NewRegistry()
+	mp := NewMeterProvider(&Options{
+		Registry: reg,
+		Views: []View{func(i Instrument) (Stream, bool) {
+			return Stream{Aggregation: AggregationSummary{
+				Objectives: map[float64]float64{0.5: 0.05},
+				MaxAge:     400 * time.Millisecond,
+				AgeBuckets: 1,
+			}}, true
+		}},
+	})
+	h, err := mp.Meter("test").Float64Histogram("duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	median := func() float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mfs[0].GetMetric()[0].GetSummary().GetQuantile()[0].GetValue()
+	}
+
+	h.Record(ctx, 1)
+	time.Sleep(300 * time.Millisecond)
+	h.Record(ctx, 2)
+	if got := median(); math.IsNaN(got) {
+		t.Fatalf("median within the window is %v", got)
+	}
+
+	// with the default five age buckets, the second observation
+	// would still be in the window
+	time.Sleep(200 * time.Millisecond)
+	if got := median(); !math.IsNaN(got) {
+		t.Errorf("median after the window is %v, want NaN", got)
+	}
+}